@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+)
+
+// audioDevice is a sound card that can be played through
+type audioDevice struct {
+	Card string // ALSA card number
+	Name string
+}
+
+// lines of /proc/asound/cards look like ` 0 [PCH            ]: HDA-Intel - HDA Intel PCH`
+var asoundCard = regexp.MustCompile(`^\s*(\d+)\s+\[[^\]]*\]:\s*(.*)$`)
+
+// audioDevices lists the output devices from ALSA, Linux only since oto can't enumerate them
+func audioDevices() ([]audioDevice, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New("listing audio devices is only supported on linux, the system default is used")
+	}
+	f, err := os.Open("/proc/asound/cards")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	devices := []audioDevice{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if m := asoundCard.FindStringSubmatch(scanner.Text()); m != nil {
+			devices = append(devices, audioDevice{Card: m[1], Name: m[2]})
+		}
+	}
+	return devices, scanner.Err()
+}
+
+// listAudioDevices prints the output devices, use the index shown to choose one with -o
+func listAudioDevices() {
+	devices, err := audioDevices()
+	if err != nil {
+		fmt.Printf("no output devices: %s\n", err.Error())
+		return
+	}
+	for i, d := range devices {
+		fmt.Printf("%d: %s\n", i+1, d.Name)
+	}
+}
+
+// selectAudioDevice points ALSA's default card at the device listed at index, before oto opens it
+func selectAudioDevice(index int) error {
+	devices, err := audioDevices()
+	if err != nil {
+		return err
+	}
+	if index < 1 || index > len(devices) {
+		return fmt.Errorf("no output device %d, there are %d", index, len(devices))
+	}
+	return os.Setenv("ALSA_CARD", devices[index-1].Card)
+}
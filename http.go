@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lucianthorr/simplesynth/synth"
+)
+
+// websocketGUID is mixed into the handshake key, as RFC 6455 has it
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// meterInterval is how often the level is sent to each connected page
+const meterInterval = 50 * time.Millisecond
+
+// controlRequest is the body posted to /control, a -ccmap target and its value from 0 to 1
+type controlRequest struct {
+	Target string  `json:"target"`
+	Value  float64 `json:"value"`
+}
+
+// startHTTP serves the control page, its sliders setting controls and its meter following meter
+func startHTTP(addr string, controls *synth.ControlQueue, meter *synth.Meter) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, controlPage)
+	})
+	mux.HandleFunc("/control", func(w http.ResponseWriter, r *http.Request) {
+		serveControl(w, r, controls)
+	})
+	mux.HandleFunc("/meter", func(w http.ResponseWriter, r *http.Request) {
+		serveMeter(w, r, meter)
+	})
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf("Error serving http: %s", err.Error())
+		}
+	}()
+	fmt.Printf("control page on http://%s/\n", listener.Addr())
+	return nil
+}
+
+// serveControl sets a -ccmap target to the value posted, as long as it's posted from the control page
+func serveControl(w http.ResponseWriter, r *http.Request, controls *synth.ControlQueue) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a target and value", http.StatusMethodNotAllowed)
+		return
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request", http.StatusForbidden)
+		return
+	}
+	var req controlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	target, err := synth.ParseCCTarget(req.Target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	controls.Set(target, req.Value)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveMeter streams the output's level down a WebSocket as JSON until the page goes away
+func serveMeter(w http.ResponseWriter, r *http.Request, meter *synth.Meter) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin websocket", http.StatusForbidden)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	hijacker, ok := w.(http.Hijacker)
+	if key == "" || !ok {
+		http.Error(w, "expected a websocket", http.StatusBadRequest)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("Error opening meter: %s", err.Error())
+		return
+	}
+	defer conn.Close()
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(accept[:]))
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		discardFrames(rw.Reader)
+	}()
+	tick := time.NewTicker(meterInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-gone:
+			return
+		case <-tick.C:
+			peak, rms := meter.Level()
+			message := fmt.Sprintf(`{"peak":%.4f,"rms":%.4f}`, peak, rms)
+			if err := writeTextFrame(rw.Writer, message); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sameOrigin reports whether a request came from the control page, or from outside a browser with no Origin
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// writeTextFrame sends one unmasked text frame, as a server does
+func writeTextFrame(w *bufio.Writer, message string) error {
+	w.WriteByte(0x81) // FIN, text
+	if len(message) < 126 {
+		w.WriteByte(byte(len(message)))
+	} else {
+		w.WriteByte(126)
+		binary.Write(w, binary.BigEndian, uint16(len(message)))
+	}
+	w.WriteString(message)
+	return w.Flush()
+}
+
+// discardFrames reads frames from the page until it closes the connection or sends a close frame
+func discardFrames(r *bufio.Reader) {
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return
+		}
+		if header[0]&0x0F == 0x8 { // close
+			return
+		}
+		length := uint64(header[1] & 0x7F)
+		switch length {
+		case 126:
+			var l uint16
+			if binary.Read(r, binary.BigEndian, &l) != nil {
+				return
+			}
+			length = uint64(l)
+		case 127:
+			if binary.Read(r, binary.BigEndian, &length) != nil {
+				return
+			}
+		}
+		if header[1]&0x80 != 0 {
+			length += 4 // the mask key
+		}
+		if _, err := r.Discard(int(length)); err != nil {
+			return
+		}
+	}
+}
+
+// controlPage is the page served at /, sliders posting to /control and a meter fed by /meter
+const controlPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>SimpleSynth</title>
+<style>
+body { font-family: sans-serif; max-width: 30em; margin: 2em auto; }
+label { display: block; margin: 1em 0 0.2em; }
+input[type=range] { width: 100%; }
+#meter { height: 1.5em; background: #ddd; position: relative; margin-top: 2em; }
+#rms, #peak { position: absolute; top: 0; bottom: 0; left: 0; }
+#rms { background: #4a4; }
+#peak { width: 2px; background: #c33; }
+</style>
+</head>
+<body>
+<h1>SimpleSynth</h1>
+<div id="sliders"></div>
+<div id="meter"><div id="rms"></div><div id="peak"></div></div>
+<script>
+const targets = ["volume", "cutoff", "resonance", "attack", "decay", "sustain", "release"];
+const sliders = document.getElementById("sliders");
+for (const target of targets) {
+	const label = document.createElement("label");
+	label.textContent = target;
+	const slider = document.createElement("input");
+	slider.type = "range";
+	slider.min = 0;
+	slider.max = 1;
+	slider.step = 0.001;
+	slider.value = 0.5;
+	slider.oninput = () => fetch("/control", {
+		method: "POST",
+		body: JSON.stringify({target: target, value: parseFloat(slider.value)}),
+	});
+	sliders.append(label, slider);
+}
+const meter = new WebSocket("ws://" + location.host + "/meter");
+meter.onmessage = (msg) => {
+	const level = JSON.parse(msg.data);
+	document.getElementById("rms").style.width = (100 * level.rms) + "%";
+	document.getElementById("peak").style.left = (100 * level.peak) + "%";
+};
+</script>
+</body>
+</html>
+`
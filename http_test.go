@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lucianthorr/simplesynth/synth"
+)
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true}, // not a browser
+		{"http://localhost:8080", true},
+		{"https://localhost:8080", true},
+		{"http://localhost:8081", false},
+		{"http://localhost", false},
+		{"http://evil.example", false},
+		{"http://localhost:8080.evil.example", false},
+		{"null", false},
+		{"://", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/meter", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := sameOrigin(r); got != tt.ok {
+			t.Errorf("Origin %q against host %s: same origin %t, want %t", tt.origin, r.Host, got, tt.ok)
+		}
+	}
+}
+
+func TestMeterRejectsOtherOrigins(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/meter", nil)
+	r.Header.Set("Origin", "http://evil.example")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	w := httptest.NewRecorder()
+	serveMeter(w, r, synth.NewMeter(&synth.AudioContext{SampleRate: 48000, NumChannels: 1, BitDepthInBytes: 2}))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("a websocket from another site got %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestControlOrigin(t *testing.T) {
+	for _, tt := range []struct {
+		origin string
+		want   int
+	}{
+		{"", http.StatusNoContent},
+		{"http://localhost:8080", http.StatusNoContent},
+		{"http://evil.example", http.StatusForbidden},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/control", strings.NewReader(`{"target":"cutoff","value":0.5}`))
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		w := httptest.NewRecorder()
+		serveControl(w, r, &synth.ControlQueue{})
+		if w.Code != tt.want {
+			t.Errorf("a post from %q got %d, want %d", tt.origin, w.Code, tt.want)
+		}
+	}
+}
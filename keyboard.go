@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucianthorr/simplesynth/synth"
+)
+
+// keyNotes lays a piano across the home row, semitones above the current C
+var keyNotes = map[byte]int64{
+	'a': 0, 'w': 1, 's': 2, 'e': 3, 'd': 4, 'f': 5, 't': 6,
+	'g': 7, 'y': 8, 'h': 9, 'u': 10, 'j': 11, 'k': 12, 'o': 13, 'l': 14,
+}
+
+const (
+	keyVelocity = 100
+	// terminals only report presses, so a key is released once its autorepeat stops
+	keyFirstRepeat = 600 * time.Millisecond
+	keyRepeat      = 120 * time.Millisecond
+)
+
+// keyboard turns the terminal into a small piano, generating the same midi events a controller would
+type keyboard struct {
+	events chan synth.Event
+	status int64 // channel bits added to every message
+	mu     sync.Mutex
+	octave int64
+	held   map[int64]time.Time // note to when it should be released unless its key repeats
+	saved  string              // terminal settings to restore
+}
+
+// startKeyboard puts the terminal in raw mode and plays keys on the channel, until Close puts it back
+func startKeyboard(channel int64) (*keyboard, error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("reading terminal settings: %s", err.Error())
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil, fmt.Errorf("setting up terminal: %s", err.Error())
+	}
+	k := &keyboard{
+		events: make(chan synth.Event, 64),
+		status: channelBits(channel),
+		octave: 4,
+		held:   map[int64]time.Time{},
+		saved:  strings.TrimSpace(saved),
+	}
+	go k.readKeys()
+	go k.releaseKeys()
+	fmt.Println("play on a-l and w-o, z / x shift down / up an octave, space silences everything")
+	return k, nil
+}
+
+// Close puts the terminal back the way it was found
+func (k *keyboard) Close() error {
+	_, err := stty(k.saved)
+	return err
+}
+
+// handler drains the events generated since it was last called, in the shape of a midi device's handler
+func (k *keyboard) handler() synth.MidiHandler {
+	var events []synth.Event
+	return func() []synth.Event {
+		events = events[:0]
+		for {
+			select {
+			case e := <-k.events:
+				events = append(events, e)
+			default:
+				return events
+			}
+		}
+	}
+}
+
+func (k *keyboard) readKeys() {
+	buf := make([]byte, 1)
+	for {
+		if _, err := os.Stdin.Read(buf); err != nil {
+			return
+		}
+		k.press(buf[0])
+	}
+}
+
+func (k *keyboard) press(key byte) {
+	for _, e := range k.pressed(key) {
+		k.events <- e
+	}
+}
+
+// pressed updates the held keys for a press, returning the events to send once unlocked
+func (k *keyboard) pressed(key byte) []synth.Event {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	switch key {
+	case 'z':
+		if k.octave > 0 {
+			k.octave--
+		}
+		return nil
+	case 'x':
+		if k.octave < 8 {
+			k.octave++
+		}
+		return nil
+	case ' ': // panic
+		for note := range k.held {
+			delete(k.held, note)
+		}
+		now := time.Now().UnixMilli()
+		return []synth.Event{
+			{Timestamp: now, Status: 0xB0 | k.status, Data1: 123},
+			{Timestamp: now, Status: 0xB0 | k.status, Data1: 120},
+		}
+	}
+	offset, ok := keyNotes[key]
+	if !ok {
+		return nil
+	}
+	note := (k.octave+1)*12 + offset // midi note 60 is C4
+	if note > 127 {
+		return nil
+	}
+	if _, down := k.held[note]; down {
+		k.held[note] = time.Now().Add(keyRepeat) // autorepeat, still held
+		return nil
+	}
+	k.held[note] = time.Now().Add(keyFirstRepeat)
+	return []synth.Event{{Timestamp: time.Now().UnixMilli(), Status: 0x90 | k.status, Data1: note, Data2: keyVelocity}}
+}
+
+// releaseKeys sends NOTE OFF for keys whose autorepeat has stopped
+func (k *keyboard) releaseKeys() {
+	for now := range time.Tick(10 * time.Millisecond) {
+		var released []int64
+		k.mu.Lock()
+		for note, release := range k.held {
+			if now.After(release) {
+				delete(k.held, note)
+				released = append(released, note)
+			}
+		}
+		k.mu.Unlock()
+		for _, note := range released {
+			k.events <- synth.Event{Timestamp: now.UnixMilli(), Status: 0x80 | k.status, Data1: note}
+		}
+	}
+}
+
+// channelBits is the low nibble of a status byte on the channel, 1 to 16 or 0 for any
+func channelBits(channel int64) int64 {
+	if channel < 1 {
+		return 0
+	}
+	return channel - 1
+}
+
+// stty runs stty against the controlling terminal
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
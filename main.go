@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -8,195 +9,741 @@ import (
 
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/hajimehoshi/oto/v2"
+	"github.com/lucianthorr/simplesynth/synth"
 	"github.com/rakyll/portmidi"
 )
 
 var (
-	listFlag    = flag.Bool("ls", false, "list available input devices")
-	monitorFlag = flag.Bool("m", false, "run a simple midi monitor")
-	deviceFlag  = flag.Int("d", -1, "device to listen")
+	listFlag     = flag.Bool("ls", false, "list available input and output devices")
+	reconnFlag   = flag.Bool("reconnect", false, "keep playing when a midi device is unplugged, picking it up again by name when it's plugged back in")
+	monitorFlag  = flag.Bool("m", false, "run a simple midi monitor")
+	mformatFlag  = flag.String("mformat", "raw", "how the midi monitor prints messages: raw, decoded (Note On C4 vel 100), hex or json")
+	kbdFlag      = flag.Bool("kbd", false, "play from the computer keyboard instead of a midi device")
+	outputFlag   = flag.Int("o", -1, "audio device to play through, from -ls, instead of the system default (linux only)")
+	channelFlag  = flag.Int64("channel", 0, "midi channel to listen to, 1 to 16, or 0 for all channels")
+	snapFlag     = flag.String("snap", "chromatic", "snap every note into a key, a root and scale like \"D minor\": major, minor, harmonicminor, dorian, mixolydian, pentatonic, minorpentatonic or blues")
+	transFlag    = flag.Int64("transpose", 0, "semitones to shift every note by")
+	octaveFlag   = flag.Int64("octave", 0, "octaves to shift every note by")
+	tuningFlag   = flag.Float64("tuning", 440, "tuning reference, the frequency of A4 in Hz")
+	sclFlag      = flag.String("scl", "", "Scala .scl file to tune to instead of equal temperament")
+	sclRootFlag  = flag.Int64("sclroot", 60, "midi note the -scl scale starts from, keeping its equal tempered pitch")
+	waveFlag     = flag.String("wave", "sine", "oscillator waveform: sine, square, saw, triangle, or white or pink noise")
+	attackFlag   = flag.Float64("attack", 0.005, "envelope attack time in seconds")
+	decayFlag    = flag.Float64("decay", 0.1, "envelope decay time in seconds")
+	sustainFlag  = flag.Float64("sustain", 1.0, "envelope sustain level, 0.0 to 1.0")
+	releaseFlag  = flag.Float64("release", 0.1, "envelope release time in seconds")
+	voicesFlag   = flag.Int("voices", 8, "maximum number of notes sounding at once, 1 for a monophonic synth")
+	polyFlag     = flag.Bool("poly", true, "play up to -voices notes at once, or with -poly=false a monophonic synth whatever -voices says")
+	priorityFlag = flag.String("priority", "last", "which held keys sound when there are more than -voices: last, high or low")
+	voicePanFlag = flag.String("voicepan", "off", "spread the voices across the stereo image: off, note (low left, high right) or roundrobin")
+	stealFade    = flag.Float64("stealfade", 5, "milliseconds a note fades out over when a new one steals its voice, 0 to cut it off")
+	retrigFlag   = flag.Bool("retrigger", false, "restart the envelope when a note takes over a sounding voice, instead of playing legato")
+	bendFlag     = flag.Float64("bendrange", 2, "pitch bend range in semitones, up and down")
+	vibratoFlag  = flag.Float64("vibratorate", 5, "speed of the mod wheel vibrato in Hz")
+	ccMapFlag    = flag.String("ccmap", "", "assign midi controllers to settings, like 74=cutoff,71=resonance,1=vibrato")
+	ccSlewFlag   = flag.Float64("ccslew", 10, "milliseconds controllers glide to new values over so sweeping a knob doesn't step, 0 to jump")
+	learnFlag    = flag.String("midilearn", "", "settings to assign to the next controllers moved on -d, one at a time, like cutoff,resonance")
+	velFlag      = flag.String("velcurve", "linear", "how key velocity maps to loudness: linear, exponential, logarithmic or fixed")
+	detuneFlag   = flag.Float64("detune", 0, "cents to detune a second oscillator by, 0 for just one")
+	unisonFlag   = flag.Int("unison", 1, "oscillators playing every note, each voice -voices allows costs this many")
+	spreadFlag   = flag.Float64("unisonspread", 20, "cents between the highest and lowest unison oscillators")
+	additiveFlag = flag.String("additive", "", "build the tone from harmonic amplitudes, fundamental first, like 1,0.5,0.33,0.25, instead of -wave")
+	tableFlag    = flag.String("wavetable", "", "single cycle waveform to play instead of -wave, a WAV file or raw 16 bit mono PCM")
+	sampleMap    = flag.String("samplemap", "", "notes that play WAV samples instead of the oscillators, like drum pads: 36=kick.wav,38=snare.wav")
+	subFlag      = flag.Float64("sub", 0, "mix of a sub oscillator an octave below each note, 0.0 (off) to 1.0")
+	ringFlag     = flag.Float64("ringmod", 0, "frequency in Hz to ring modulate the oscillators with, 0 for none")
+	ringRatio    = flag.Float64("ringratio", 0, "ring modulate each note at this multiple of its own frequency instead of -ringmod, 0 for none")
+	fmFlag       = flag.Bool("fm", false, "2 operator FM, a sine modulating the phase of the oscillator")
+	fmRatioFlag  = flag.Float64("fmratio", 1, "FM modulator frequency as a multiple of the note's")
+	fmIndexFlag  = flag.Float64("fmindex", 1, "FM modulation index, how bright and clangorous it gets")
+	subWaveFlag  = flag.String("subwave", "square", "sub oscillator waveform: sine or square")
+	sync2Flag    = flag.Float64("sync2", 0, "hard sync: play the oscillator at this multiple of the note, reset by a silent master at the note every cycle, 0 for none")
+	analogFlag   = flag.Float64("analog", 0, "how far each note's pitch and level wander, like an old analog synth, 0.0 (clean) to 1.0")
+	bandFlag     = flag.Bool("bandlimit", true, "smooth the saw and square so high notes don't alias, -bandlimit=false for the raw shapes")
+	pwFlag       = flag.Float64("pulsewidth", 0.5, "duty cycle of the square wave, 0.05 to 0.95")
+	pwmRateFlag  = flag.Float64("pwmrate", 0.5, "speed of the LFO sweeping the pulse width in Hz")
+	pwmDepthFlag = flag.Float64("pwmdepth", 0, "how far the LFO sweeps the pulse width either side of -pulsewidth, 0 for no sweep")
+	tremRateFlag = flag.Float64("tremolorate", 5, "speed of the tremolo in Hz")
+	tremDepFlag  = flag.Float64("tremolodepth", 0, "depth of the tremolo, 0.0 (off) to 1.0")
+	seedFlag     = flag.Int64("seed", 0, "seed for everything random, the noise waveforms, -arp random, the -unison oscillators' starting points and -analog drift, so renders come out byte for byte the same, 0 seeds from the clock")
+	arpFlag      = flag.String("arp", "off", "arpeggiate held keys: off, up, down, updown or random")
+	bpmFlag      = flag.Float64("bpm", synth.DefaultBPM, "tempo in beats a minute the arpeggiator and the metronome keep to, until a midi clock takes over with -sync")
+	arpDivFlag   = flag.Float64("arpdiv", 16, "arpeggiator steps per whole note, 4 for quarter notes, 16 for sixteenths")
+	syncFlag     = flag.Bool("sync", false, "step the arpeggiator with incoming midi clock, following its tempo")
+	arpOctFlag   = flag.Int("arpoctaves", 1, "octaves the arpeggio climbs through")
+	latchFlag    = flag.Bool("latch", false, "hold every note on until its key is pressed again, for drones and hands-free arpeggios")
+	glideFlag    = flag.Float64("glide", 0, "milliseconds to glide from one note to the next, 0 for none")
+	glideMode    = flag.String("glidemode", "always", "which notes glide: always, or legato for only notes played while another is held, detached notes jumping to pitch with a fresh attack")
+	legatoGlide  = flag.Bool("legatoglide", false, "glide only into notes played while another is held, the same as -glidemode legato")
+	cutoffFlag   = flag.Float64("cutoff", 20000, "low-pass filter cutoff in Hz")
+	fAttackFlag  = flag.Float64("fattack", 0.005, "filter envelope attack time in seconds")
+	fDecayFlag   = flag.Float64("fdecay", 0.3, "filter envelope decay time in seconds")
+	fSustainFlag = flag.Float64("fsustain", 0, "filter envelope sustain level, 0.0 to 1.0")
+	fReleaseFlag = flag.Float64("frelease", 0.1, "filter envelope release time in seconds")
+	fEnvFlag     = flag.Float64("fenvamount", 0, "octaves the filter envelope opens the cutoff by, negative closes it, 0 for none")
+	velModFlag   = flag.Float64("velmod", 0, "octaves that striking a key at full velocity opens its filter by, 0 for none")
+	keyTrackFlag = flag.Float64("keytrack", 0, "how far the filter cutoff follows each note's pitch from middle C, 0 fixed to 1 one-for-one")
+	velEnvFlag   = flag.Float64("velenv", 0, "how much harder struck keys attack faster and softer ones slower, 0 to 1, 0 for none")
+	velDecayFlag = flag.Bool("velenvdecay", false, "let -velenv scale the decay time as well as the attack")
+	resFlag      = flag.Float64("resonance", 0, "low-pass filter resonance, 0.0 to 1.0")
+	routingFlag  = flag.String("filterrouting", "single", "the low-pass alone (single), into a high-pass for a band-pass (series), or summed with one (parallel)")
+	hpCutoff     = flag.Float64("hpcutoff", 20, "high-pass filter cutoff in Hz, for -filterrouting series or parallel")
+	hpResonance  = flag.Float64("hpresonance", 0, "high-pass filter resonance, 0.0 to 1.0")
+	phaserRate   = flag.Float64("phaserrate", 0.3, "speed of the phaser sweep in Hz")
+	phaserDepth  = flag.Float64("phaserdepth", 0.7, "how far the phaser sweeps, 0.0 to 1.0")
+	phaserStages = flag.Int("phaserstages", 4, "all-pass stages in the phaser, each pair makes a notch")
+	phaserMix    = flag.Float64("phasermix", 0, "loudness of the phaser against the dry signal, 0.0 (off) to 1.0")
+	flangerRate  = flag.Float64("flangerrate", 0.2, "speed of the flanger sweep in Hz")
+	flangerDepth = flag.Float64("flangerdepth", 0.8, "how far the flanger sweeps its delay, 0.0 to 1.0")
+	flangerFB    = flag.Float64("flangerfeedback", 0.5, "flanger feedback, -0.95 to 0.95, negative inverts it for a hollower sound")
+	flangerMix   = flag.Float64("flangermix", 0, "loudness of the flanger against the dry signal, 0.0 (off) to 1.0")
+	chorusRate   = flag.Float64("chorusrate", 0.8, "speed of the chorus sweep in Hz")
+	chorusDepth  = flag.Float64("chorusdepth", 5, "milliseconds the chorus sweeps its delays by, up to 10")
+	chorusMix    = flag.Float64("chorusmix", 0, "loudness of the chorus against the dry signal, 0.0 (off) to 1.0")
+	delayFlag    = flag.Float64("delaytime", 300, "time between echoes in milliseconds")
+	delaySync    = flag.String("delaysync", "", "time between echoes as a note at the -bpm tempo in place of -delaytime, like 1/4, 1/8d for dotted or 1/8t for triplets")
+	feedbackFlag = flag.Float64("delayfeedback", 0.4, "how much of each echo feeds the next, 0.0 to 0.95")
+	delayMixFlag = flag.Float64("delaymix", 0, "loudness of the echoes, 0.0 (off) to 1.0")
+	reverbSize   = flag.Float64("reverbsize", 0.5, "how long the reverb's tail rings, 0.0 (a small room) to 1.0 (a hall)")
+	reverbDamp   = flag.Float64("reverbdamp", 0.5, "how quickly the reverb's highs die away, 0.0 (bright) to 1.0 (dark)")
+	reverbMix    = flag.Float64("reverbmix", 0, "loudness of the reverb against the dry signal, 0.0 (off) to 1.0, heavier on the CPU than the delay")
+	eqFreqFlag   = flag.Float64("eqfreq", 1000, "center frequency of the EQ band in Hz")
+	eqGainFlag   = flag.Float64("eqgain", 0, "dB the EQ band boosts by, or cuts by when negative, 0 for no EQ")
+	eqQFlag      = flag.Float64("eqq", 1, "Q of the EQ band, higher for a narrower band")
+	bitsFlag     = flag.Int("bits", 16, "bit depth to crush the output to, 1 to 16 (clean)")
+	holdFlag     = flag.Int("downsample", 1, "hold each output sample for this many, 1 for none")
+	driveFlag    = flag.Float64("drive", 0, "soft clipping distortion for warmth, 0 (clean) up, around 10 is heavy")
+	dcFlag       = flag.Bool("dcblock", true, "filter DC offset out of the output, -dcblock=false to hear it raw")
+	compRatio    = flag.Float64("compratio", 1, "compress the output over -compthreshold by this ratio, 4 for 4:1, 1 for none")
+	compThresh   = flag.Float64("compthreshold", -18, "level in dBFS the compressor starts turning the output down over")
+	compAttack   = flag.Float64("compattack", 10, "milliseconds the compressor takes to come down on a peak")
+	compRelease  = flag.Float64("comprelease", 100, "milliseconds the compressor takes to recover after one")
+	compMakeup   = flag.Float64("compmakeup", 0, "dB to turn the compressed output back up by")
+	limitFlag    = flag.Bool("limiter", true, "limit the output so peaks never go over -threshold")
+	threshFlag   = flag.Float64("threshold", -0.3, "level in dBFS the limiter holds the output under")
+	clickFlag    = flag.Float64("click", 0, "play a metronome click, at this many beats a minute unless -bpm is given, 0 for none")
+	clickSig     = flag.String("clicksig", "4/4", "time signature of the metronome, its first beat of each bar accented")
+	gateFlag     = flag.Float64("gate", math.Inf(-1), "level in dBFS to mute the output under, quieting the hum of tails and feedback, -inf for no gate")
+	touchFlag    = flag.String("aftertouch", "off", "what aftertouch changes: off, amplitude or cutoff")
+	fadeFlag     = flag.Float64("fadeout", 20, "milliseconds to fade out over when stopping, so the sound doesn't pop")
+	oscFlag      = flag.String("osc", "", "listen for OSC control on this UDP address, like :9000")
+	httpFlag     = flag.String("http", "", "serve a control page with sliders and a level meter on this address, like localhost:8080")
+	noAudioFlag  = flag.Bool("noaudio", false, "run the synth without opening an audio device, to check the midi side on headless machines")
+	debugFlag    = flag.Bool("debug", false, "time generating each buffer and warn on stderr, with a count, whenever it falls behind the audio device, and count midi read errors")
+	meterFlag    = flag.Bool("meter", false, "print the output's peak and RMS level to stderr every 100ms")
+	recFlag      = flag.String("rec", "", "record the output to this WAV file")
+	volumeFlag   = flag.Float64("volume", 0.8, "master volume, 0.0 to 1.0, also set live by midi CC7")
+	widthFlag    = flag.Float64("width", 1, "stereo width, 0 for mono, 1 for normal and above 1 wider")
+	haasFlag     = flag.Float64("haas", 0, "milliseconds to delay one side by, up to 30, to widen even a mono sound")
+	panFlag      = flag.Float64("pan", 0, "stereo position, -1.0 (left) to 1.0 (right), also set live by midi CC10")
+	rateFlag     = flag.Int("samplerate", 48000, "audio sample rate in Hz, higher rates sound better but cost more CPU")
+	bufferFlag   = flag.Int("buffersize", 512, "frames per audio buffer, smaller buffers lower the latency (512 at 48kHz is ~10ms) but crackle sooner on a busy machine")
+	depthFlag    = flag.Int("depth", 16, "bits a sample, 16 or 24 bit integers or 32 bit floats, deeper than 16 for -rec and -midifile only")
+	chanFlag     = flag.Int("channels", 2, "audio output channels, 1 for mono or 2 for stereo")
+	loadFlag     = flag.String("load", "", "load the sound's settings from a JSON preset, flags given alongside it win")
+	saveFlag     = flag.String("save", "", "save the sound's settings to a JSON preset")
+	splitFlag    = flag.Int64("split", -1, "split the keyboard at this midi note, the keys below playing -lower and the rest -upper")
+	layerFlag    = flag.Bool("layer", false, "play both -lower and -upper across the whole keyboard instead of splitting it")
+	lowerFlag    = flag.String("lower", "", "preset for the keys below -split, or the first layer")
+	upperFlag    = flag.String("upper", "", "preset for the keys from -split up, or the second layer")
+	bankFlag     = flag.String("presetbank", "", "directory of JSON presets for program change to pick between, in file name order, all built at start up")
+	toneFlag     = flag.Float64("tone", 0, "play a test tone at this frequency in Hz at -12 dBFS instead of the synth, with no midi needed, to check the audio path")
+	sweepFlag    = flag.Float64("sweep", 0, "sweep the -tone up to this frequency in Hz and back, 10 seconds each way")
+	midiFileFlag = flag.String("midifile", "", "render this midi file to a WAV file instead of playing live, written to -rec or alongside it")
 )
 
-type AudioContext struct {
-	SampleRate      int
-	NumChannels     int
-	BitDepthInBytes int
-}
+func main() {
+	var devices deviceList
+	flag.Var(&devices, "d", "device to listen, from -ls, repeat or comma separate to listen to several at once")
+	flag.Parse()
+	if *loadFlag != "" {
+		if err := loadPreset(*loadFlag); err != nil {
+			log.Fatal(fmt.Errorf("Error loading preset: %s", err.Error()))
+		}
+	}
+	if *saveFlag != "" {
+		if err := savePreset(*saveFlag); err != nil {
+			log.Fatal(fmt.Errorf("Error saving preset: %s", err.Error()))
+		}
+	}
+	sound := parsePatch()
+	zones, err := parseZones()
+	if err != nil {
+		log.Fatal(err)
+	}
+	programs, err := parsePresetBank()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(programs) > 0 && len(zones) > 0 {
+		log.Fatal(errors.New("A preset bank plays across the whole keyboard, it can't be split or layered as well"))
+	}
+	if *channelFlag < 0 || *channelFlag > 16 {
+		log.Fatal(fmt.Errorf("Channel must be 1 to 16, or 0 for all, got %d", *channelFlag))
+	}
+	mformat, err := parseMonitorFormat(*mformatFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	beatsPerBar, err := parseTimeSignature(*clickSig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	learn, err := parseLearnTargets(*learnFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-type midiHandler func() []portmidi.Event                       // pulls and returns a list of midi events
-type midiTranslator func() (freq, velocity float64, gate bool) // translates those events into parameters for a sound generator
-type soundGen func(buf []byte) (int, error)                    // generates the sineWave and reads it to a buffer
+	// audio bootstrap
+	validateAudioFlags()
+	ac := &synth.AudioContext{
+		SampleRate:      *rateFlag,
+		NumChannels:     *chanFlag,
+		BitDepthInBytes: *depthFlag / 8,
+	}
+	if *depthFlag == 32 {
+		ac.Format = synth.SampleFloat
+	}
+	seed := *seedFlag
+	clock := synth.NewClock(ac, sound.bpm) // the tempo every sound shares
 
-func (sg soundGen) Read(buf []byte) (int, error) {
-	return sg(buf)
-}
+	var faders []*synth.Fader // one at the end of each sound's effects
+	controls := &synth.ControlQueue{}
+	var meter *synth.Meter  // measuring the output for the control page, when it's served
+	var levels *synth.Meter // measuring it for -meter
+	if *meterFlag {
+		levels = synth.NewMeterWindow(ac, levelInterval.Seconds())
+		go printLevels(levels, clock)
+	}
+	learned := synth.CCMap{} // by -midilearn, on top of every sound's -ccmap
+	// ending is the effects at the end of a sound's chain, the click if asked and a fader for quitting
+	ending := func(click bool) []synth.Effect {
+		effects := []synth.Effect{}
+		if click && *clickFlag > 0 { // after the delay, so the click doesn't echo
+			effects = append(effects, synth.NewClick(ac, clock, beatsPerBar))
+		}
+		fader := synth.NewFader(ac)
+		faders = append(faders, fader)
+		return append(effects, fader)
+	}
+	buildSound := func(handler synth.MidiHandler, sound patch, controls *synth.ControlQueue, ends ...synth.Effect) (synth.SoundGen, *synth.VoicePool) {
+		for cc, target := range learned {
+			sound.ccMap[cc] = target
+		}
+		params := &synth.Params{
+			Channel:   *channelFlag,
+			Transpose: *transFlag + *octaveFlag*12,
+			Snap:      sound.snap,
 
-func main() {
-	flag.Parse()
+			Volume:      math.Max(0, math.Min(1, *volumeFlag)),
+			Pan:         math.Max(-1, math.Min(1, *panFlag)),
+			Width:       math.Max(0, *widthFlag),
+			Haas:        math.Max(0, math.Min(30, *haasFlag)) / 1000,
+			BendRange:   *bendFlag,
+			VibratoRate: *vibratoFlag,
+
+			CCMap:         sound.ccMap,
+			CCSlew:        math.Max(0, *ccSlewFlag) / 1000,
+			Controls:      controls,
+			VelocityCurve: sound.velCurve,
+			Detune:        *detuneFlag,
+			UnisonSpread:  *spreadFlag,
+			Sub:           math.Max(0, math.Min(1, *subFlag)),
+			SubWave:       sound.subWave,
+			RingMod:       math.Max(0, *ringFlag),
+			RingRatio:     math.Max(0, *ringRatio),
+			FM:            *fmFlag,
+			FMRatio:       math.Max(0, *fmRatioFlag),
+			FMIndex:       math.Max(0, *fmIndexFlag),
+			Wavetable:     sound.wavetable,
+			Harmonics:     sound.harmonics,
+			Samples:       sound.samples,
+			BandLimit:     *bandFlag,
+			Analog:        math.Max(0, math.Min(1, *analogFlag)),
+			SyncRatio:     syncRatio(*sync2Flag),
+			Glide:         *glideFlag,
+			GlideMode:     sound.glide,
+
+			Cutoff:          *cutoffFlag,
+			Resonance:       *resFlag,
+			FilterRouting:   sound.routing,
+			HPCutoff:        *hpCutoff,
+			HPResonance:     *hpResonance,
+			VelocityCutoff:  *velModFlag,
+			KeyTrack:        math.Max(0, math.Min(1, *keyTrackFlag)),
+			FilterEnvAmount: *fEnvFlag,
+			Aftertouch:      sound.aftertouch,
+			Drive:           math.Max(0, *driveFlag),
+			DCBlock:         *dcFlag,
+
+			CompRatio:     math.Max(1, *compRatio),
+			CompThreshold: math.Min(0, *compThresh),
+			CompAttack:    math.Max(0, *compAttack) / 1000,
+			CompRelease:   math.Max(0, *compRelease) / 1000,
+			CompMakeup:    *compMakeup,
+
+			Limiter:          *limitFlag,
+			LimiterThreshold: math.Min(0, *threshFlag),
+			GateThreshold:    *gateFlag,
+
+			PulseWidth: math.Max(0.05, math.Min(0.95, *pwFlag)),
+			PWMRate:    *pwmRateFlag,
+			PWMDepth:   math.Max(0, math.Min(0.45, *pwmDepthFlag)),
+
+			TremoloRate:  *tremRateFlag,
+			TremoloDepth: math.Max(0, math.Min(1, *tremDepFlag)),
+
+			Arp:         sound.arp,
+			Clock:       clock,
+			ArpDivision: math.Max(1, *arpDivFlag),
+			ArpOctaves:  *arpOctFlag,
+			Sync:        *syncFlag,
+			Latch:       *latchFlag,
+		}
+		pool := synth.NewVoicePool(voiceCount(), sound.env)
+		if seed != 0 {
+			pool.Seed(seed)
+		}
+		pool.SetFilterEnvelope(sound.filterEnv)
+		pool.SetPriority(sound.priority)
+		pool.SetRetrigger(*retrigFlag)
+		pool.SetFingeredLegato(sound.glide == synth.GlideLegato)
+		pool.SetVelocityEnvelope(math.Max(0, *velEnvFlag), *velDecayFlag)
+		pool.SetStealFade(math.Max(0, *stealFade) / 1000)
+		pool.SetUnison(*unisonFlag)
+		pool.SetVoicePan(sound.voicePan)
+		pool.SetNoteMap(sound.notes)
+		translator := synth.MakeMidiTranslator(ac, params, handler, pool)
+		effects := []synth.Effect{}
+		if *bitsFlag < 16 || *holdFlag > 1 {
+			effects = append(effects, synth.NewBitcrusher(*bitsFlag, *holdFlag))
+		}
+		if *phaserMix > 0 {
+			effects = append(effects, synth.NewPhaser(ac, *phaserRate, *phaserDepth, math.Min(1, *phaserMix), *phaserStages))
+		}
+		if *flangerMix > 0 {
+			effects = append(effects, synth.NewFlanger(ac, *flangerRate, *flangerDepth, *flangerFB, math.Min(1, *flangerMix)))
+		}
+		if *chorusMix > 0 {
+			effects = append(effects, synth.NewChorus(ac, *chorusRate, *chorusDepth, math.Min(1, *chorusMix)))
+		}
+		if sound.delayNote > 0 {
+			effects = append(effects, synth.NewSyncedDelay(ac, clock, sound.delayNote, *feedbackFlag, *delayMixFlag))
+		} else if *delayFlag > 0 {
+			effects = append(effects, synth.NewDelay(ac, *delayFlag, *feedbackFlag, *delayMixFlag))
+		}
+		if *reverbMix > 0 {
+			effects = append(effects, synth.NewReverb(ac, *reverbSize, *reverbDamp, math.Min(1, *reverbMix)))
+		}
+		if *eqGainFlag != 0 {
+			effects = append(effects, synth.NewBiquad(ac, synth.BiquadPeaking, *eqFreqFlag, *eqQFlag, *eqGainFlag))
+		}
+		effects = append(effects, ends...)
+		return synth.MakeOscGen(ac, params, translator, sound.wave, effects...), pool
+	}
+	buildSynth := func(handler synth.MidiHandler) synth.SoundGen {
+		var gen synth.SoundGen
+		if len(programs) > 0 { // each one built from its preset as the bank's made, before anything plays
+			queues := controls.Split(len(programs))
+			bank := []synth.Program{}
+			for i, p := range programs {
+				i, sound, preset := i, p.load(), presetFromFlags()
+				tempo := sound.bpm
+				if *syncFlag {
+					tempo = 0 // following the midi clock's
+				}
+				bank = append(bank, synth.Program{BPM: tempo, Build: func(handler synth.MidiHandler) (synth.SoundGen, *synth.VoicePool) {
+					if err := applyPreset(preset); err != nil {
+						log.Fatal(fmt.Errorf("Error loading preset: %s", err.Error()))
+					}
+					return buildSound(handler, sound, queues[i])
+				}})
+			}
+			fader := synth.NewFader(ac)
+			faders = append(faders, fader)
+			gen = synth.MakeBank(ac, handler, *channelFlag, clock, fader, bank...)
+			if *clickFlag > 0 { // played apart, since no one program lasts
+				gen = synth.MakeMixer(ac, synth.MakeClickGen(ac, clock, beatsPerBar, ending(false)...), gen)
+			}
+		} else if len(zones) > 0 { // each one's preset loaded into the flags in turn, its sound built from them
+			keys := []synth.Zone{}
+			for _, z := range zones {
+				keys = append(keys, z.keys)
+			}
+			handlers := synth.MakeZoneHandlers(handler, keys...)
+			queues := controls.Split(len(zones))
+			gens := []synth.SoundGen{}
+			for i, z := range zones {
+				zoneGen, _ := buildSound(handlers[i], z.load(), queues[i], ending(i == 0)...)
+				gens = append(gens, zoneGen)
+			}
+			gen = synth.MakeMixer(ac, gens...)
+		} else {
+			gen, _ = buildSound(handler, sound, controls, ending(true)...)
+		}
+		if meter != nil {
+			gen = synth.MakeRecorder(gen, meter)
+		}
+		if levels != nil {
+			gen = synth.MakeRecorder(gen, levels)
+		}
+		return gen
+	}
+
+	if *midiFileFlag != "" {
+		renderMidi(ac, buildSynth, sound.env.Release)
+		return
+	}
+
+	if *toneFlag > 0 {
+		fader := synth.NewFader(ac)
+		playLive(ac, synth.MakeToneGen(ac, *toneFlag, *sweepFlag, fader), []*synth.Fader{fader}, clock)
+		return
+	}
+
+	var remote synth.MidiHandler // notes from the network, played alongside the local ones
+	if *oscFlag != "" {
+		osc, err := startOSC(*oscFlag, *channelFlag, controls)
+		if err != nil {
+			log.Fatal(fmt.Errorf("Error starting OSC: %s", err.Error()))
+		}
+		defer osc.Close()
+		remote = osc.handler()
+	}
+	if *httpFlag != "" {
+		meter = synth.NewMeter(ac)
+		if err := startHTTP(*httpFlag, controls, meter); err != nil {
+			log.Fatal(fmt.Errorf("Error starting http: %s", err.Error()))
+		}
+	}
+
+	if *kbdFlag {
+		kbd, err := startKeyboard(*channelFlag)
+		if err != nil {
+			log.Fatal(fmt.Errorf("Error starting keyboard: %s", err.Error()))
+		}
+		defer kbd.Close()
+		atFatal = func() { kbd.Close() }
+		gen := buildSynth(mergeHandlers(kbd.handler(), remote))
+		playLive(ac, gen, faders, clock)
+		return
+	}
 
 	// midi bootstrap
 	portmidi.Initialize()
 	defer portmidi.Terminate()
 	if *listFlag {
 		listMidiDevices()
+		fmt.Println("outputs:")
+		listAudioDevices()
 		return
 	}
-	if 0 < *deviceFlag && *deviceFlag < portmidi.CountDevices()-1 {
-		in, err := portmidi.NewInputStream(portmidi.DeviceID(*deviceFlag-1), 64)
-		if err != nil {
-			log.Fatal(fmt.Errorf("Error creating stream: %s", err.Error()))
+	if len(devices) > 0 {
+		streams := []midiStream{}
+		var watch *reconnector // looking after the streams, for -reconnect
+		if *reconnFlag {
+			watch = &reconnector{}
+			defer watch.Close()
 		}
-		defer in.Close()
+		for _, device := range devices {
+			id, err := inputDevice(device)
+			if err != nil {
+				log.Fatal(fmt.Errorf("Error creating stream: %s", err.Error()))
+			}
+			in, err := portmidi.NewInputStream(id, 64)
+			if err != nil {
+				log.Fatal(fmt.Errorf("Error creating stream: %s", err.Error()))
+			}
 
-		in.Listen()
-		midiHandler := makeMidiHandler(in)
-		midiTranslator := makeMidiTranslator(midiHandler)
-		if *monitorFlag {
-			runMidiMonitor(midiHandler) // midi testing
+			if watch != nil { // only polled, Listen's goroutine would carry on reading it once it's closed
+				streams = append(streams, watch.add(portmidi.Info(id).Name, in))
+			} else {
+				defer in.Close()
+				in.Listen()
+				streams = append(streams, in)
+			}
+		}
+		midiHandler := makeMidiHandler(streams...)
+		if *debugFlag {
+			go reportMidiErrors()
+		}
+		if *monitorFlag && *noAudioFlag {
+			midiHandler = makeMidiMonitor(midiHandler, mformat) // watch the messages while they drive the synth
+		} else if *monitorFlag {
+			runMidiMonitor(midiHandler, mformat) // midi testing
 		}
+		if len(learn) > 0 {
+			learnControllers(midiHandler, learn, learned)
+		}
+		gen := buildSynth(mergeHandlers(midiHandler, remote))
+		playLive(ac, gen, faders, clock)
+
+	} else if remote != nil {
+		gen := buildSynth(remote)
+		playLive(ac, gen, faders, clock)
+	} else if *monitorFlag || len(learn) > 0 {
+		listMidiDevices()
+		fmt.Println("Specify an input device to monitor or learn from")
+	}
+}
 
-		// audio bootstrap
-		ac := &AudioContext{
-			SampleRate:      48000,
-			NumChannels:     2,
-			BitDepthInBytes: 2, // 16-bit
+// playLive plays the generator until interrupted, fading out before the player closes
+func playLive(ac *synth.AudioContext, gen synth.SoundGen, faders []*synth.Fader, clock *synth.Clock) {
+	var recorder *synth.WavWriter
+	if *recFlag != "" {
+		var err error
+		recorder, err = synth.NewWavWriter(*recFlag, ac)
+		if err != nil {
+			fatal(fmt.Errorf("Error creating recording: %s", err.Error()))
 		}
+		gen = synth.MakeRecorder(gen, recorder)
+	}
+	if *debugFlag {
+		gen = timeGen(ac, gen, clock)
+	}
+	wait := make(chan os.Signal, 1)
+	signal.Notify(wait, os.Interrupt, syscall.SIGTERM)
 
+	if *noAudioFlag {
+		runWithoutAudio(ac, gen, wait)
+	} else {
+		if ac.BitDepthInBytes != 2 {
+			fatal(fmt.Errorf("Oto only plays 16 bit audio, -depth %d is for rendering a -midifile or recording with -noaudio", *depthFlag))
+		}
+		if *outputFlag > 0 {
+			if err := selectAudioDevice(*outputFlag); err != nil {
+				fatal(fmt.Errorf("Error selecting output: %s", err.Error()))
+			}
+		}
 		ctx, ready, err := oto.NewContext(ac.SampleRate, ac.NumChannels, ac.BitDepthInBytes)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		<-ready
 		// connecting the pieces
-		p := ctx.NewPlayer(makeSineGen(ac, midiTranslator))
+		p := ctx.NewPlayer(gen)
 		defer runtime.KeepAlive(p)
-		p.(oto.BufferSizeSetter).SetBufferSize(512 * ac.NumChannels * ac.BitDepthInBytes) // 2048
+		p.(oto.BufferSizeSetter).SetBufferSize(*bufferFlag * ac.NumChannels * ac.BitDepthInBytes)
 		p.Play()
 
-		wait := make(chan os.Signal, 1)
-		signal.Notify(wait, os.Interrupt, syscall.SIGTERM)
 		<-wait
 
-	} else if *monitorFlag {
-		listMidiDevices()
-		fmt.Println("Specify an input device to monitor")
+		fadeOut := *fadeFlag / 1000
+		select {
+		case <-fadeOutAll(faders, fadeOut):
+		case <-time.After(time.Duration(fadeOut*float64(time.Second)) + time.Second): // the audio device has stalled
+		}
+		if err := p.Close(); err != nil {
+			log.Printf("Error closing player: %s", err.Error())
+		}
 	}
-}
 
-// listDevices currently available, use the index shown to specify which device you'd like to use
-func listMidiDevices() {
-	for i := 0; i < portmidi.CountDevices(); i++ {
-		info := portmidi.Info(portmidi.DeviceID(i))
-		if info.IsInputAvailable {
-			fmt.Printf("%d: %s\n", i+1, info.Name)
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			fatal(fmt.Errorf("Error finishing recording: %s", err.Error()))
 		}
 	}
 }
 
-// builds a function to poll midi events
-func makeMidiHandler(in *portmidi.Stream) midiHandler {
-	return func() []portmidi.Event {
-		res, err := in.Poll()
-		if err != nil {
-			log.Fatal(fmt.Errorf("Error polling: %s", err.Error()))
+// atFatal runs before fatal exits, skipping the deferred cleanup, so -kbd can put the terminal back
+var atFatal func()
+
+// fatal is log.Fatal for once the sound is playing, running atFatal first
+func fatal(err error) {
+	if atFatal != nil {
+		atFatal()
+	}
+	log.Fatal(err)
+}
+
+// timeGen warns on stderr when the generator takes longer than a buffer lasts, and of tempo changes
+func timeGen(ac *synth.AudioContext, gen synth.SoundGen, clock *synth.Clock) synth.SoundGen {
+	bytesPerSecond := float64(ac.SampleRate * ac.NumChannels * ac.BitDepthInBytes)
+	underruns := 0
+	var warned, told time.Time
+	tempo := clock.BPM()
+	return func(buf []byte) (int, error) {
+		start := time.Now()
+		n, err := gen(buf)
+		took := time.Since(start)
+		if bpm := clock.BPM(); math.Abs(bpm-tempo) >= 0.5 && time.Since(told) >= time.Second {
+			told, tempo = time.Now(), bpm
+			fmt.Fprintf(os.Stderr, "tempo %.1f bpm\n", bpm)
 		}
-		filteredEvents := []portmidi.Event{}
-		if res {
-			events, err := in.Read(1024)
-			if err != nil {
-				log.Fatal(fmt.Errorf("Error reading: %s", err.Error()))
-			}
-			for i := range events {
-				if 0x08 <= events[i].Status&0xF0 && events[i].Status&0xF0 < 0xF0 {
-					// filters out sysex and system real time messages
-					filteredEvents = append(filteredEvents, events[i])
-				}
+		budget := time.Duration(float64(n) / bytesPerSecond * float64(time.Second))
+		if took > budget {
+			underruns++
+			if time.Since(warned) >= time.Second {
+				warned = time.Now()
+				fmt.Fprintf(os.Stderr, "underrun %d: took %s to generate %s of audio (%.0f%% CPU), try a larger -buffersize\n",
+					underruns, took.Round(time.Microsecond), budget.Round(time.Microsecond), 100*took.Seconds()/budget.Seconds())
 			}
 		}
-		return filteredEvents
+		return n, err
+	}
+}
+
+// levelInterval is how often -meter prints the level
+const levelInterval = 100 * time.Millisecond
+
+// printLevels prints the level the meter measures and the tempo to stderr, out of the way of the midi monitor
+func printLevels(meter *synth.Meter, clock *synth.Clock) {
+	for range time.Tick(levelInterval) {
+		peak, rms := meter.Level()
+		fmt.Fprintf(os.Stderr, "peak %6.1f dBFS  rms %6.1f dBFS  %5.1f bpm\n", 20*math.Log10(peak), 20*math.Log10(rms), clock.BPM())
+	}
+}
+
+// fadeOutAll fades every sound out together, the channel closing once they're all silent
+func fadeOutAll(faders []*synth.Fader, seconds float64) <-chan struct{} {
+	fading := []<-chan struct{}{}
+	for _, f := range faders {
+		fading = append(fading, f.FadeOut(seconds))
+	}
+	done := make(chan struct{})
+	go func() {
+		for _, silent := range fading {
+			<-silent
+		}
+		close(done)
+	}()
+	return done
+}
+
+// validateAudioFlags rejects a sample rate or buffer size that can't work and warns about ones that probably won't
+func validateAudioFlags() {
+	if *rateFlag <= 0 {
+		log.Fatal(fmt.Errorf("Sample rate must be positive, got %d", *rateFlag))
+	}
+	if *chanFlag != 1 && *chanFlag != 2 {
+		log.Fatal(fmt.Errorf("Channels must be 1 (mono) or 2 (stereo), got %d", *chanFlag))
+	}
+	if *bufferFlag <= 0 {
+		log.Fatal(fmt.Errorf("Buffer size must be positive, got %d", *bufferFlag))
 	}
+	if *depthFlag != 16 && *depthFlag != 24 && *depthFlag != 32 {
+		log.Fatal(fmt.Errorf("Depth must be 16, 24 or 32 bits, got %d", *depthFlag))
+	}
+	if *rateFlag < 8000 || *rateFlag > 192000 {
+		log.Printf("Warning: a sample rate of %d Hz is unusual and may not be supported by the device", *rateFlag)
+	}
+	if *bufferFlag < 32 || *bufferFlag > 16384 {
+		latency := float64(*bufferFlag) / float64(*rateFlag) * 1000
+		log.Printf("Warning: a buffer of %d frames is %.1fms, expect crackles or lag", *bufferFlag, latency)
+	}
+}
+
+// renderMidi renders the -midifile offline into a WAV file, with no midi or audio devices involved
+func renderMidi(ac *synth.AudioContext, buildSynth func(synth.MidiHandler) synth.SoundGen, release float64) {
+	events, err := synth.ReadMidiFile(*midiFileFlag)
+	if err != nil {
+		log.Fatal(fmt.Errorf("Error reading midi file: %s", err.Error()))
+	}
+	out := *recFlag
+	if out == "" {
+		out = strings.TrimSuffix(*midiFileFlag, filepath.Ext(*midiFileFlag)) + ".wav"
+	}
+	w, err := synth.NewWavWriter(out, ac)
+	if err != nil {
+		log.Fatal(fmt.Errorf("Error creating recording: %s", err.Error()))
+	}
+	if err := synth.RenderMidiFile(ac, events, buildSynth, w, release+0.5); err != nil {
+		w.Close()
+		log.Fatal(fmt.Errorf("Error rendering: %s", err.Error()))
+	}
+	if err := w.Close(); err != nil {
+		log.Fatal(fmt.Errorf("Error finishing recording: %s", err.Error()))
+	}
+	fmt.Printf("Rendered %s to %s\n", *midiFileFlag, out)
 }
 
+// midiPollInterval is how often the modes without a synth poll the handler
+const midiPollInterval = 2 * time.Millisecond
+
 // runMidiMonitor simply prints the midi messages received, for testing
-func runMidiMonitor(handler midiHandler) {
-	for {
+func runMidiMonitor(handler synth.MidiHandler, format monitorFormat) {
+	monitor := makeMidiMonitor(handler, format)
+	for range time.Tick(midiPollInterval) {
+		monitor()
+	}
+}
+
+// makeMidiMonitor prints the midi messages in the format as they pass through to whatever reads the handler
+func makeMidiMonitor(handler synth.MidiHandler, format monitorFormat) synth.MidiHandler {
+	return func() []synth.Event {
 		events := handler()
 		for i := range events {
 			e := events[i]
-			fmt.Printf("ts: %d\tstatus: %d\tdata1: %d\tdata2: %d\n", e.Timestamp, e.Status, e.Data1, e.Data2)
+			fmt.Println(format.format(e))
 		}
+		return events
 	}
 }
 
-// builds a functions to convert midi events into a frequency and gate
-func makeMidiTranslator(handler midiHandler) midiTranslator {
-	note := int64(0)
-	velocity := float64(0)
-	gate := false
-	return func() (float64, float64, bool) {
-		events := handler()
-		for i := range events {
-			if events[i].Status == 0x90 { // NOTE ON
-				gate = true
-				note = events[i].Data1
-				velocity = float64(events[i].Data2) / 128.0
-			}
-			if events[i].Status == 0x80 { // NOTE OFF
-				if events[i].Data1 == note {
-					gate = false
-					velocity = 0.0
-				}
+// runWithoutAudio pulls from the generator at a player's pace without opening the audio device
+func runWithoutAudio(ac *synth.AudioContext, gen synth.SoundGen, stop <-chan os.Signal) {
+	buf := make([]byte, *bufferFlag*ac.NumChannels*ac.BitDepthInBytes)
+	tick := time.NewTicker(time.Duration(*bufferFlag) * time.Second / time.Duration(ac.SampleRate))
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+			if _, err := gen.Read(buf); err != nil {
+				fatal(fmt.Errorf("Error generating: %s", err.Error()))
 			}
 		}
-		return NOTE_MAP[note], velocity, gate
 	}
 }
 
-func makeSineGen(ac *AudioContext, translator midiTranslator) soundGen {
-	var lastFreq float64
-	var lastVelocity float64
-	var lastGate bool
-	var pos float64
-	return func(buf []byte) (int, error) {
-		bytesRead := 0
-		bytesPerSample := ac.BitDepthInBytes * ac.NumChannels
-		numSamples := len(buf) / bytesPerSample
-		deltaT := float64(1) / float64(ac.SampleRate)
-		for sampleIdx := 0; sampleIdx < numSamples; sampleIdx++ {
-			freq, velocity, gate := translator()
-
-			if gate && !lastGate {
-				pos = 0
-			}
-
-			if freq != lastFreq { // resolve clicking on new notes and between frequency changes
-				pos = (lastFreq * pos) / freq
-			}
-
-			if gate {
-				velocity *= 0.8 // scale the volume down a little
-			} else {
-				velocity = lastVelocity * 0.9995 // decay
-			}
+// voiceCount is how many notes can sound at once, a single voice for -mono
+func voiceCount() int {
+	if !*polyFlag {
+		return 1
+	}
+	return *voicesFlag
+}
 
-			b := int16(math.Sin(2*math.Pi*float64(freq)*pos) * (math.MaxInt16 - 1) * velocity)
+// syncRatio is the hard sync ratio, 0 for none and at least 1 otherwise
+func syncRatio(ratio float64) float64 {
+	if ratio <= 0 {
+		return 0
+	}
+	return math.Max(1, ratio)
+}
 
-			for channelIdx := 0; channelIdx < ac.NumChannels; channelIdx++ {
-				idx := (bytesPerSample * sampleIdx) + (channelIdx * ac.BitDepthInBytes)
-				buf[idx] = byte(b)
-				buf[idx+1] = byte(b >> 8)
-				bytesRead = idx + 2
-			}
+// parseNoteLength reads a note length like 1/4, 1/8d or 1/8t as a fraction of a whole note
+func parseNoteLength(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	scale := 1.0
+	switch s[len(s)-1] {
+	case 'd', '.':
+		scale = 1.5
+	case 't':
+		scale = 2.0 / 3
+	}
+	var n, d int
+	if count, err := fmt.Sscanf(strings.TrimRight(s, "d.t"), "%d/%d", &n, &d); err != nil || count != 2 || n < 1 || d < 1 {
+		return 0, fmt.Errorf("%q is not a note length like 1/4, 1/8d or 1/8t", s)
+	}
+	return float64(n) / float64(d) * scale, nil
+}
 
-			lastFreq = freq
-			lastVelocity = velocity
-			lastGate = gate
-			pos += deltaT
-		}
-		return bytesRead, nil
+// parseTimeSignature reads a time signature like 3/4 or 6/8, returning its beats to the bar
+func parseTimeSignature(s string) (int, error) {
+	var beats, unit int
+	if n, err := fmt.Sscanf(s, "%d/%d", &beats, &unit); err != nil || n != 2 || beats < 1 || unit < 1 {
+		return 0, fmt.Errorf("%q is not a time signature like 4/4", s)
 	}
+	return beats, nil
 }
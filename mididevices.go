@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucianthorr/simplesynth/synth"
+	"github.com/rakyll/portmidi"
+)
+
+// deviceList is the -d flag, repeated or comma separated
+type deviceList []int
+
+func (d *deviceList) String() string {
+	indexes := []string{}
+	for _, device := range *d {
+		indexes = append(indexes, strconv.Itoa(device))
+	}
+	return strings.Join(indexes, ",")
+}
+
+func (d *deviceList) Set(value string) error {
+	for _, index := range strings.Split(value, ",") {
+		device, err := strconv.Atoi(strings.TrimSpace(index))
+		if err != nil {
+			return fmt.Errorf("%q is not a device index", index)
+		}
+		*d = append(*d, device)
+	}
+	return nil
+}
+
+// listDevices currently available, use the index shown to specify which device you'd like to use
+func listMidiDevices() {
+	for i := 0; i < portmidi.CountDevices(); i++ {
+		info := portmidi.Info(portmidi.DeviceID(i))
+		if info.IsInputAvailable {
+			fmt.Printf("%d: %s\n", i+1, info.Name)
+		}
+	}
+}
+
+// inputDevice is the portmidi device for an index printed by listMidiDevices, which counts from 1
+func inputDevice(index int) (portmidi.DeviceID, error) {
+	id, err := deviceID(index, portmidi.CountDevices())
+	if err != nil {
+		return 0, err
+	}
+	if !portmidi.Info(id).IsInputAvailable {
+		return 0, fmt.Errorf("device %d is not an input", index)
+	}
+	return id, nil
+}
+
+// deviceID is the portmidi id for an index counting from 1, out of count devices
+func deviceID(index, count int) (portmidi.DeviceID, error) {
+	if index < 1 || index > count {
+		return 0, fmt.Errorf("no device %d, there are %d", index, count)
+	}
+	return portmidi.DeviceID(index - 1), nil
+}
+
+// midiStream is a midi input as the handler uses it, a portmidi stream or a stand-in
+type midiStream interface {
+	Poll() (bool, error)
+	Read(max int) ([]portmidi.Event, error)
+	Listen() <-chan portmidi.Event
+	Close() error
+}
+
+// maxMidiFailures is how many polls in a row a device can fail before it's dropped, about a second
+const maxMidiFailures = 1000
+
+// midiErrors counts every failed poll or read, for -debug
+var midiErrors int64
+
+// builds a function to poll midi events from one or more devices, dropping any that keep failing
+func makeMidiHandler(streams ...midiStream) synth.MidiHandler {
+	filteredEvents := make([]synth.Event, 0, 1024)
+	parsers := make([]midiParser, len(streams)) // each device keeps its own running status
+	failures := make([]int, len(streams))       // polls in a row each device has failed
+	return func() []synth.Event {
+		filteredEvents = filteredEvents[:0]
+		for i := 0; i < len(streams); i++ {
+			var err error
+			filteredEvents, err = pollStream(streams[i], &parsers[i], filteredEvents)
+			if err == nil {
+				failures[i] = 0
+				continue
+			}
+			atomic.AddInt64(&midiErrors, 1)
+			if failures[i]++; failures[i] == 1 {
+				log.Printf("Error reading midi device, retrying: %s", err.Error())
+			}
+			if failures[i] >= maxMidiFailures {
+				log.Printf("Error reading midi device, dropping it after %d tries: %s", failures[i], err.Error())
+				streams = append(streams[:i], streams[i+1:]...)
+				parsers = append(parsers[:i], parsers[i+1:]...)
+				failures = append(failures[:i], failures[i+1:]...)
+				i--
+				if len(streams) == 0 {
+					log.Printf("No midi devices left")
+				}
+			}
+		}
+		return filteredEvents
+	}
+}
+
+// reportMidiErrors prints to stderr how many times the midi devices failed each second they did, for -debug
+func reportMidiErrors() {
+	for range time.Tick(time.Second) {
+		if count := atomic.SwapInt64(&midiErrors, 0); count > 0 {
+			fmt.Fprintf(os.Stderr, "midi: %d errors in the last second\n", count)
+		}
+	}
+}
+
+// mergeHandlers polls each handler in turn and returns all their events together, a nil handler is skipped
+func mergeHandlers(handlers ...synth.MidiHandler) synth.MidiHandler {
+	live := []synth.MidiHandler{}
+	for _, h := range handlers {
+		if h != nil {
+			live = append(live, h)
+		}
+	}
+	if len(live) == 1 {
+		return live[0]
+	}
+	var events []synth.Event
+	return func() []synth.Event {
+		events = events[:0]
+		for _, h := range live {
+			events = append(events, h()...)
+		}
+		return events
+	}
+}
+
+// pollStream appends a device's waiting events to filteredEvents, reading only when there are some
+func pollStream(in midiStream, parser *midiParser, filteredEvents []synth.Event) ([]synth.Event, error) {
+	res, err := in.Poll()
+	if err != nil {
+		return filteredEvents, fmt.Errorf("polling: %s", err.Error())
+	}
+	if res {
+		events, err := in.Read(1024)
+		if err != nil {
+			return filteredEvents, fmt.Errorf("reading: %s", err.Error())
+		}
+		for i := range events {
+			e, ok := parser.parse(synth.Event{
+				Timestamp: int64(events[i].Timestamp),
+				Status:    events[i].Status,
+				Data1:     events[i].Data1,
+				Data2:     events[i].Data2,
+			})
+			if ok {
+				filteredEvents = append(filteredEvents, e)
+			}
+		}
+	}
+	return filteredEvents, nil
+}
+
+// isClockMessage reports whether the status is midi clock, a tick or a START, CONTINUE or STOP
+func isClockMessage(status int64) bool {
+	return status == 0xF8 || status == 0xFA || status == 0xFB || status == 0xFC
+}
+
+// midiParser checks events from a device, filling in running status
+type midiParser struct {
+	running int64 // status of the last channel message, 0 before there's been one
+}
+
+// parse returns the complete message, or false for sysex, system messages besides clock and junk
+func (p *midiParser) parse(e synth.Event) (synth.Event, bool) {
+	switch {
+	case e.Status < 0 || e.Status > 0xFF:
+		log.Printf("Skipping malformed midi message: status %#x", e.Status)
+		return e, false
+	case e.Status >= 0xF8: // realtime, which can come between any other bytes and leaves running status alone
+		return e, isClockMessage(e.Status)
+	case e.Status >= 0xF0: // sysex and system common, which cancel running status
+		p.running = 0
+		return e, false
+	case e.Status >= 0x80:
+		p.running = e.Status
+	case p.running == 0:
+		log.Printf("Skipping malformed midi message: data %#x without a status", e.Status)
+		return e, false
+	default: // running status, the first byte is really the first data byte
+		e.Status, e.Data1, e.Data2 = p.running, e.Status, e.Data1
+	}
+	if kind := e.Status & 0xF0; kind == 0xC0 || kind == 0xD0 {
+		e.Data2 = 0 // program change and channel aftertouch only have the one data byte
+	}
+	if e.Data1 < 0 || e.Data1 > 127 || e.Data2 < 0 || e.Data2 > 127 {
+		log.Printf("Skipping malformed midi message: %#x %#x %#x", e.Status, e.Data1, e.Data2)
+		return e, false
+	}
+	return e, true
+}
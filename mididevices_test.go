@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lucianthorr/simplesynth/synth"
+	"github.com/rakyll/portmidi"
+)
+
+func TestDeviceID(t *testing.T) {
+	tests := []struct {
+		index, count int
+		want         portmidi.DeviceID
+		ok           bool
+	}{
+		{1, 3, 0, true},
+		{2, 3, 1, true},
+		{3, 3, 2, true}, // the last device listed
+		{0, 3, 0, false},
+		{4, 3, 0, false},
+		{-1, 3, 0, false},
+		{1, 0, 0, false},
+	}
+	for _, tt := range tests {
+		got, err := deviceID(tt.index, tt.count)
+		if (err == nil) != tt.ok {
+			t.Errorf("deviceID(%d, %d) error %v, want ok %t", tt.index, tt.count, err, tt.ok)
+			continue
+		}
+		if tt.ok && got != tt.want {
+			t.Errorf("deviceID(%d, %d) = %d, want %d", tt.index, tt.count, got, tt.want)
+		}
+	}
+}
+
+// fakeStream stands in for a midi device, handing out scripted reads one per poll
+type fakeStream struct {
+	reads  []fakeRead
+	closed bool
+}
+
+// fakeRead is what one Read returns
+type fakeRead struct {
+	events []portmidi.Event
+	err    error
+}
+
+func (s *fakeStream) Poll() (bool, error) {
+	return len(s.reads) > 0, nil
+}
+
+func (s *fakeStream) Read(max int) ([]portmidi.Event, error) {
+	r := s.reads[0]
+	s.reads = s.reads[1:]
+	if len(r.events) > max {
+		r.events = r.events[:max]
+	}
+	return r.events, r.err
+}
+
+func (s *fakeStream) Listen() <-chan portmidi.Event {
+	return nil
+}
+
+func (s *fakeStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+// messages is a run of portmidi events, one message of up to three bytes each
+func messages(msgs ...[]int64) []portmidi.Event {
+	events := []portmidi.Event{}
+	for _, m := range msgs {
+		m = append(m, 0, 0)
+		events = append(events, portmidi.Event{Status: m[0], Data1: m[1], Data2: m[2]})
+	}
+	return events
+}
+
+func TestMidiHandlerFiltering(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []portmidi.Event
+		want []synth.Event
+	}{
+		{"note", messages([]int64{0x90, 60, 100}, []int64{0x80, 60, 0}),
+			[]synth.Event{{Status: 0x90, Data1: 60, Data2: 100}, {Status: 0x80, Data1: 60}}},
+		{"sysex", messages([]int64{0xF0, 0x7E, 0x7F}, []int64{0xF7}, []int64{0x90, 60, 100}),
+			[]synth.Event{{Status: 0x90, Data1: 60, Data2: 100}}},
+		{"clock", messages([]int64{0xFA}, []int64{0xF8}, []int64{0xFC}),
+			[]synth.Event{{Status: 0xFA}, {Status: 0xF8}, {Status: 0xFC}}},
+		{"active sensing and reset", messages([]int64{0xFE}, []int64{0xFF}), []synth.Event{}},
+		{"running status", messages([]int64{0x91, 60, 100}, []int64{64, 90}, []int64{67, 80}),
+			[]synth.Event{{Status: 0x91, Data1: 60, Data2: 100}, {Status: 0x91, Data1: 64, Data2: 90}, {Status: 0x91, Data1: 67, Data2: 80}}},
+		{"running status through a clock tick", messages([]int64{0xB0, 1, 10}, []int64{0xF8}, []int64{1, 20}),
+			[]synth.Event{{Status: 0xB0, Data1: 1, Data2: 10}, {Status: 0xF8}, {Status: 0xB0, Data1: 1, Data2: 20}}},
+		{"running status ended by sysex", messages([]int64{0x90, 60, 100}, []int64{0xF0, 1, 2}, []int64{64, 90}),
+			[]synth.Event{{Status: 0x90, Data1: 60, Data2: 100}}},
+	}
+	for _, tt := range tests {
+		handler := makeMidiHandler(&fakeStream{reads: []fakeRead{{events: tt.in}}})
+		got := handler()
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestMidiHandlerMergesStreams(t *testing.T) {
+	a := &fakeStream{reads: []fakeRead{{events: messages([]int64{0x90, 60, 100}, []int64{62, 100})}}}
+	b := &fakeStream{reads: []fakeRead{{events: messages([]int64{70, 100})}}} // running status doesn't carry over from a
+	got := makeMidiHandler(a, b)()
+	want := []synth.Event{{Status: 0x90, Data1: 60, Data2: 100}, {Status: 0x90, Data1: 62, Data2: 100}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// sounding polls the translator once and lists the notes it has gated on
+func sounding(translator synth.MidiTranslator) []int64 {
+	notes := []int64{}
+	for _, v := range translator() {
+		if v.Gate {
+			notes = append(notes, v.Note)
+		}
+	}
+	return notes
+}
+
+func TestTranslatorFromStream(t *testing.T) {
+	ac := &synth.AudioContext{SampleRate: 1000, NumChannels: 1, BitDepthInBytes: 2} // a poll every sample
+	stream := &fakeStream{reads: []fakeRead{
+		{events: messages([]int64{0x90, 60, 100}, []int64{64, 100})}, // a chord in running status
+		{events: messages([]int64{0xF8}, []int64{60, 0})},            // a clock tick, then NOTE ON at 0 releasing 60
+		{events: messages([]int64{0xF0, 0x7E}, []int64{64, 0})},      // sysex ends running status, so 64 is left on
+		{events: messages([]int64{0x80, 64, 0})},
+	}}
+	pool := synth.NewVoicePool(4, synth.Envelope{Attack: 0.01, Decay: 0.1, Sustain: 0.5, Release: 0.1})
+	translator := synth.MakeMidiTranslator(ac, synth.DefaultParams(), makeMidiHandler(stream), pool)
+	for i, want := range [][]int64{{60, 64}, {64}, {64}, {}} {
+		got := sounding(translator)
+		if len(got) != len(want) {
+			t.Errorf("read %d: sounding %v, want %v", i, got, want)
+			continue
+		}
+		for j := range got {
+			if got[j] != want[j] {
+				t.Errorf("read %d: sounding %v, want %v", i, got, want)
+				break
+			}
+		}
+	}
+}
+
+// failing is a stream whose next n reads fail
+func failing(n int) []fakeRead {
+	reads := make([]fakeRead, n)
+	for i := range reads {
+		reads[i].err = errors.New("unplugged")
+	}
+	return reads
+}
+
+func TestMidiHandlerRetries(t *testing.T) {
+	note := fakeRead{events: messages([]int64{0x90, 60, 100})}
+	tests := []struct {
+		name  string
+		reads []fakeRead
+		notes int // NOTE ONs that get through
+	}{
+		{"a few failures", append(failing(5), note), 1},
+		{"just short of giving up", append(failing(maxMidiFailures-1), note), 1},
+		{"given up on", append(failing(maxMidiFailures), note), 0},
+		{"a good read resets the count", append(append(append(failing(maxMidiFailures-1), note), failing(maxMidiFailures-1)...), note), 2},
+	}
+	for _, tt := range tests {
+		stream := &fakeStream{reads: tt.reads}
+		handler := makeMidiHandler(stream)
+		notes := 0
+		for range tt.reads {
+			notes += len(handler())
+		}
+		if notes != tt.notes {
+			t.Errorf("%s: %d notes got through, want %d", tt.name, notes, tt.notes)
+		}
+	}
+}
+
+func TestMidiHandlerDropsOneStream(t *testing.T) {
+	bad := &fakeStream{reads: failing(maxMidiFailures)}
+	good := &fakeStream{}
+	handler := makeMidiHandler(bad, good)
+	for i := 0; i < maxMidiFailures; i++ {
+		handler()
+	}
+	good.reads = []fakeRead{{events: messages([]int64{0x90, 60, 100})}}
+	bad.reads = []fakeRead{{events: messages([]int64{0x90, 62, 100})}} // too late, it's been dropped
+	if got := handler(); len(got) != 1 || got[0].Data1 != 60 {
+		t.Errorf("got %v, want only the good stream's note", got)
+	}
+}
+
+// busyStream always has the same events waiting, like a device being played flat out
+type busyStream struct {
+	events []portmidi.Event
+}
+
+func (s busyStream) Poll() (bool, error)                    { return true, nil }
+func (s busyStream) Read(max int) ([]portmidi.Event, error) { return s.events, nil }
+func (s busyStream) Listen() <-chan portmidi.Event          { return nil }
+func (s busyStream) Close() error                           { return nil }
+
+func BenchmarkMidiHandler(b *testing.B) {
+	stream := busyStream{messages([]int64{0x90, 60, 100}, []int64{0xF8}, []int64{64, 100}, []int64{0xB0, 1, 64}, []int64{0x80, 60, 0})}
+	handler := makeMidiHandler(stream, stream)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		handler()
+	}
+}
+
+func TestMidiHandlerDoesntAllocate(t *testing.T) {
+	stream := busyStream{messages([]int64{0x90, 60, 100}, []int64{0xF8}, []int64{64, 100})}
+	handler := makeMidiHandler(stream)
+	if allocs := testing.AllocsPerRun(100, func() { handler() }); allocs != 0 {
+		t.Errorf("polling allocated %g times a call", allocs)
+	}
+}
+
+func TestMidiParser(t *testing.T) {
+	tests := []struct {
+		name string
+		in   [][]int64
+		want []synth.Event
+	}{
+		{"running status across a clock tick", [][]int64{{0x90, 60, 100}, {0xF8}, {62, 100}},
+			[]synth.Event{{Status: 0x90, Data1: 60, Data2: 100}, {Status: 0xF8}, {Status: 0x90, Data1: 62, Data2: 100}}},
+		{"running status across active sensing", [][]int64{{0x90, 60, 100}, {0xFE}, {62, 100}},
+			[]synth.Event{{Status: 0x90, Data1: 60, Data2: 100}, {Status: 0x90, Data1: 62, Data2: 100}}},
+		{"sysex mid-stream", [][]int64{{0x90, 60, 100}, {0xF0, 0x41, 0x10}, {0x42, 0x12}, {0xF7}, {0x80, 60, 0}},
+			[]synth.Event{{Status: 0x90, Data1: 60, Data2: 100}, {Status: 0x80, Data1: 60}}},
+		{"song position cancels running status", [][]int64{{0xB0, 7, 100}, {0xF2, 0, 0}, {7, 50}},
+			[]synth.Event{{Status: 0xB0, Data1: 7, Data2: 100}}},
+		{"data without a status", [][]int64{{60, 100}, {0x90, 60, 100}},
+			[]synth.Event{{Status: 0x90, Data1: 60, Data2: 100}}},
+		{"program change is 2 bytes", [][]int64{{0xC0, 5, 99}, {6}},
+			[]synth.Event{{Status: 0xC0, Data1: 5}, {Status: 0xC0, Data1: 6}}},
+		{"channel aftertouch is 2 bytes", [][]int64{{0xD3, 90, 1}, {80}},
+			[]synth.Event{{Status: 0xD3, Data1: 90}, {Status: 0xD3, Data1: 80}}},
+		{"pitch bend is 3 bytes", [][]int64{{0xE0, 0, 64}, {127, 127}},
+			[]synth.Event{{Status: 0xE0, Data1: 0, Data2: 64}, {Status: 0xE0, Data1: 127, Data2: 127}}},
+		{"data out of range", [][]int64{{0x90, 128, 100}, {0x90, 60, -1}, {0x90, 60, 100}},
+			[]synth.Event{{Status: 0x90, Data1: 60, Data2: 100}}},
+		{"status out of range", [][]int64{{0x100, 60, 100}, {-1, 60, 100}}, []synth.Event{}},
+	}
+	for _, tt := range tests {
+		var p midiParser
+		got := []synth.Event{}
+		for _, e := range messages(tt.in...) {
+			if e, ok := p.parse(synth.Event{Status: e.Status, Data1: e.Data1, Data2: e.Data2}); ok {
+				got = append(got, e)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
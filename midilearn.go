@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lucianthorr/simplesynth/synth"
+)
+
+// learnTimeout is how long midi learn waits for a controller before giving up on a setting
+const learnTimeout = 30 * time.Second
+
+// parseLearnTargets reads the -midilearn list of settings, the same names -ccmap takes
+func parseLearnTargets(s string) ([]synth.CCTarget, error) {
+	targets := []synth.CCTarget{}
+	if strings.TrimSpace(s) == "" {
+		return targets, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		target, err := synth.ParseCCTarget(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// learnControllers assigns each target to the next controller moved, printing and saving the mapping
+func learnControllers(handler synth.MidiHandler, targets []synth.CCTarget, learned synth.CCMap) {
+	ccMap, err := synth.ParseCCMap(*ccMapFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	taken := map[int64]bool{} // a knob keeps sending as it turns, so it only counts for one setting
+	for _, target := range targets {
+		fmt.Printf("move the controller for %s\n", target)
+		if cc, ok := nextController(handler, taken); ok {
+			taken[cc] = true
+			learned[cc] = target
+			ccMap[cc] = target
+			fmt.Printf("%s is controller %d\n", target, cc)
+		} else {
+			fmt.Printf("no controller moved in %s, leaving %s unassigned\n", learnTimeout, target)
+		}
+	}
+	fmt.Printf("-ccmap %s\n", ccMap)
+	if *saveFlag != "" {
+		if err := flag.Set("ccmap", ccMap.String()); err != nil {
+			log.Fatal(fmt.Errorf("Error saving preset: %s", err.Error()))
+		}
+		if err := savePreset(*saveFlag); err != nil {
+			log.Fatal(fmt.Errorf("Error saving preset: %s", err.Error()))
+		}
+	}
+}
+
+// nextController waits up to learnTimeout for a controller not learned already
+func nextController(handler synth.MidiHandler, learned map[int64]bool) (int64, bool) {
+	deadline := time.Now().Add(learnTimeout)
+	for time.Now().Before(deadline) {
+		for _, e := range handler() {
+			if e.Status&0xF0 == 0xB0 && !learned[e.Data1] {
+				return e.Data1, true
+			}
+		}
+		time.Sleep(midiPollInterval)
+	}
+	return 0, false
+}
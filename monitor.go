@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lucianthorr/simplesynth/synth"
+)
+
+// monitorFormat is how the midi monitor prints each message
+type monitorFormat int
+
+const (
+	monitorRaw     monitorFormat = iota // the event's fields in decimal
+	monitorDecoded                      // what the message means, like Note On C4 vel 100
+	monitorHex                          // the message's bytes
+	monitorJSON                         // a JSON object a line, for piping to other tools
+)
+
+var monitorFormatNames = map[monitorFormat]string{
+	monitorRaw:     "raw",
+	monitorDecoded: "decoded",
+	monitorHex:     "hex",
+	monitorJSON:    "json",
+}
+
+// parseMonitorFormat looks up a monitor format by its name: raw, decoded, hex or json
+func parseMonitorFormat(name string) (monitorFormat, error) {
+	for f, n := range monitorFormatNames {
+		if n == name {
+			return f, nil
+		}
+	}
+	return monitorRaw, fmt.Errorf("unknown monitor format %q", name)
+}
+
+// realtimeNames are the system realtime messages that reach the monitor, the only system messages that do
+var realtimeNames = map[int64]string{
+	0xF8: "Clock",
+	0xFA: "Start",
+	0xFB: "Continue",
+	0xFC: "Stop",
+}
+
+var pitchNames = [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// noteName names a midi note with its octave, 60 being C4
+func noteName(note int64) string {
+	return fmt.Sprintf("%s%d", pitchNames[note%12], note/12-1)
+}
+
+// format prints the event as the format lays it out
+func (f monitorFormat) format(e synth.Event) string {
+	switch f {
+	case monitorDecoded:
+		return fmt.Sprintf("ts: %d\t%s", e.Timestamp, decodeEvent(e))
+	case monitorHex:
+		bytes := []string{}
+		for _, b := range eventBytes(e) {
+			bytes = append(bytes, fmt.Sprintf("%02X", b))
+		}
+		return fmt.Sprintf("ts: %d\t%s", e.Timestamp, strings.Join(bytes, " "))
+	case monitorJSON:
+		data, _ := json.Marshal(eventFields(e))
+		return string(data)
+	}
+	return fmt.Sprintf("ts: %d\tstatus: %d\tdata1: %d\tdata2: %d", e.Timestamp, e.Status, e.Data1, e.Data2)
+}
+
+// eventBytes are the bytes of the message as it came over the wire
+func eventBytes(e synth.Event) []int64 {
+	if e.Status >= 0xF0 {
+		return []int64{e.Status}
+	}
+	if kind := e.Status & 0xF0; kind == 0xC0 || kind == 0xD0 {
+		return []int64{e.Status, e.Data1}
+	}
+	return []int64{e.Status, e.Data1, e.Data2}
+}
+
+// decodeEvent describes the message, like "ch 1  Note On C4 vel 100"
+func decodeEvent(e synth.Event) string {
+	if name, ok := realtimeNames[e.Status]; ok {
+		return name
+	}
+	channel := fmt.Sprintf("ch %d  ", e.Status&0x0F+1)
+	switch e.Status & 0xF0 {
+	case 0x80:
+		return channel + fmt.Sprintf("Note Off %s vel %d", noteName(e.Data1), e.Data2)
+	case 0x90:
+		return channel + fmt.Sprintf("Note On %s vel %d", noteName(e.Data1), e.Data2)
+	case 0xA0:
+		return channel + fmt.Sprintf("Aftertouch %s %d", noteName(e.Data1), e.Data2)
+	case 0xB0:
+		return channel + fmt.Sprintf("CC%d=%d", e.Data1, e.Data2)
+	case 0xC0:
+		return channel + fmt.Sprintf("Program Change %d", e.Data1)
+	case 0xD0:
+		return channel + fmt.Sprintf("Channel Aftertouch %d", e.Data1)
+	case 0xE0:
+		return channel + fmt.Sprintf("Pitch Bend %+d", (e.Data2<<7|e.Data1)-0x2000)
+	}
+	return fmt.Sprintf("Unknown %#x %d %d", e.Status, e.Data1, e.Data2)
+}
+
+// eventFields are the message's meaning as the JSON monitor prints it
+func eventFields(e synth.Event) map[string]interface{} {
+	fields := map[string]interface{}{"ts": e.Timestamp}
+	if name, ok := realtimeNames[e.Status]; ok {
+		fields["type"] = strings.ToLower(name)
+		return fields
+	}
+	fields["channel"] = e.Status&0x0F + 1
+	note := func(kind string) {
+		fields["type"], fields["note"], fields["name"] = kind, e.Data1, noteName(e.Data1)
+	}
+	switch e.Status & 0xF0 {
+	case 0x80:
+		note("note_off")
+		fields["velocity"] = e.Data2
+	case 0x90:
+		note("note_on")
+		fields["velocity"] = e.Data2
+	case 0xA0:
+		note("aftertouch")
+		fields["pressure"] = e.Data2
+	case 0xB0:
+		fields["type"], fields["controller"], fields["value"] = "cc", e.Data1, e.Data2
+	case 0xC0:
+		fields["type"], fields["program"] = "program_change", e.Data1
+	case 0xD0:
+		fields["type"], fields["pressure"] = "channel_aftertouch", e.Data1
+	case 0xE0:
+		fields["type"], fields["bend"] = "pitch_bend", (e.Data2<<7|e.Data1)-0x2000
+	default:
+		fields["type"], fields["status"], fields["data1"], fields["data2"] = "unknown", e.Status, e.Data1, e.Data2
+	}
+	return fields
+}
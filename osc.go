@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/lucianthorr/simplesynth/synth"
+)
+
+// oscServer takes OSC messages over UDP, /synth/note and the /synth/ -ccmap targets
+type oscServer struct {
+	conn     net.PacketConn
+	controls *synth.ControlQueue
+	events   chan synth.Event
+	status   int64 // channel bits added to every note
+}
+
+// oscMessage is a single OSC message, its numeric arguments as floats
+type oscMessage struct {
+	address string
+	args    []float64
+}
+
+// startOSC listens for OSC on host:port, playing notes on the channel
+func startOSC(addr string, channel int64, controls *synth.ControlQueue) (*oscServer, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &oscServer{
+		conn:     conn,
+		controls: controls,
+		events:   make(chan synth.Event, 64),
+		status:   channelBits(channel),
+	}
+	go s.serve()
+	fmt.Printf("listening for OSC on %s\n", conn.LocalAddr())
+	return s, nil
+}
+
+// Close stops listening
+func (s *oscServer) Close() error {
+	return s.conn.Close()
+}
+
+// handler drains the notes received since it was last called, in the shape of a midi device's handler
+func (s *oscServer) handler() synth.MidiHandler {
+	var events []synth.Event
+	return func() []synth.Event {
+		events = events[:0]
+		for {
+			select {
+			case e := <-s.events:
+				events = append(events, e)
+			default:
+				return events
+			}
+		}
+	}
+}
+
+func (s *oscServer) serve() {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Error reading OSC: %s", err.Error())
+			}
+			return
+		}
+		messages, err := parseOSC(buf[:n], nil)
+		if err != nil {
+			log.Printf("Error parsing OSC packet: %s", err.Error())
+			continue
+		}
+		for _, m := range messages {
+			s.dispatch(m)
+		}
+	}
+}
+
+// dispatch routes a message to the note or setting it addresses, ignoring any it doesn't know
+func (s *oscServer) dispatch(m oscMessage) {
+	name := strings.TrimPrefix(m.address, "/synth/")
+	if name == m.address || len(m.args) == 0 {
+		return
+	}
+	if name == "note" {
+		velocity := 0.0
+		if len(m.args) > 1 {
+			velocity = m.args[1]
+		}
+		note := int64(math.Round(m.args[0]))
+		if note < 0 || note > 127 {
+			return
+		}
+		status := int64(0x80)
+		if velocity > 0 {
+			status = 0x90
+		}
+		s.events <- synth.Event{
+			Timestamp: time.Now().UnixMilli(),
+			Status:    status | s.status,
+			Data1:     note,
+			Data2:     int64(math.Max(0, math.Min(127, math.Round(velocity)))),
+		}
+		return
+	}
+	if target, err := synth.ParseCCTarget(name); err == nil {
+		s.controls.Set(target, m.args[0])
+	}
+}
+
+// parseOSC reads the messages out of a packet, unpacking bundles, and appends them to messages
+func parseOSC(packet []byte, messages []oscMessage) ([]oscMessage, error) {
+	r := bytes.NewReader(packet)
+	address, err := readOSCString(r)
+	if err != nil {
+		return messages, err
+	}
+	if address == "#bundle" {
+		var timetag uint64 // bundles are applied as they arrive
+		if err := binary.Read(r, binary.BigEndian, &timetag); err != nil {
+			return messages, fmt.Errorf("bundle time tag: %s", err.Error())
+		}
+		for r.Len() > 0 {
+			var size int32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return messages, fmt.Errorf("bundle element size: %s", err.Error())
+			}
+			if size < 0 || int(size) > r.Len() {
+				return messages, errors.New("bundle element is truncated")
+			}
+			element := make([]byte, size)
+			r.Read(element)
+			if messages, err = parseOSC(element, messages); err != nil {
+				return messages, err
+			}
+		}
+		return messages, nil
+	}
+	if !strings.HasPrefix(address, "/") {
+		return messages, fmt.Errorf("%q is not an OSC address", address)
+	}
+	m := oscMessage{address: address}
+	if r.Len() == 0 {
+		return append(messages, m), nil // old senders can leave out the type tags
+	}
+	tags, err := readOSCString(r)
+	if err != nil {
+		return messages, err
+	}
+	if !strings.HasPrefix(tags, ",") {
+		return messages, fmt.Errorf("%q is not an OSC type tag string", tags)
+	}
+	for _, tag := range tags[1:] {
+		switch tag {
+		case 'f':
+			var f float32
+			err = binary.Read(r, binary.BigEndian, &f)
+			m.args = append(m.args, float64(f))
+		case 'i':
+			var i int32
+			err = binary.Read(r, binary.BigEndian, &i)
+			m.args = append(m.args, float64(i))
+		case 'd':
+			var d float64
+			err = binary.Read(r, binary.BigEndian, &d)
+			m.args = append(m.args, d)
+		case 'h':
+			var h int64
+			err = binary.Read(r, binary.BigEndian, &h)
+			m.args = append(m.args, float64(h))
+		case 'T':
+			m.args = append(m.args, 1)
+		case 'F':
+			m.args = append(m.args, 0)
+		case 's':
+			_, err = readOSCString(r) // no string arguments are used, but they're skipped over
+		default:
+			return messages, fmt.Errorf("unsupported OSC type tag %q", tag)
+		}
+		if err != nil {
+			return messages, fmt.Errorf("arguments for %s: %s", address, err.Error())
+		}
+	}
+	return append(messages, m), nil
+}
+
+// readOSCString reads a null terminated string padded out to a multiple of 4 bytes
+func readOSCString(r *bytes.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", errors.New("unterminated OSC string")
+		}
+		if c == 0 {
+			break
+		}
+		b.WriteByte(c)
+	}
+	for pad := (b.Len() + 1) % 4; pad != 0 && pad < 4; pad++ {
+		if _, err := r.ReadByte(); err != nil {
+			return "", errors.New("OSC string is missing its padding")
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/lucianthorr/simplesynth/synth"
+)
+
+// patch is a sound's settings parsed from the flags, the rest being read as it's built
+type patch struct {
+	wave       synth.Waveform
+	wavetable  synth.Wavetable
+	harmonics  synth.Harmonics
+	samples    synth.SampleMap
+	subWave    synth.Waveform
+	velCurve   synth.VelocityCurve
+	aftertouch synth.AftertouchTarget
+	glide      synth.GlideMode
+	priority   synth.NotePriority
+	voicePan   synth.VoicePan
+	arp        synth.ArpPattern
+	delayNote  float64 // whole notes between echoes, following the tempo, 0 for -delaytime
+	routing    synth.FilterRouting
+	env        synth.Envelope
+	filterEnv  synth.Envelope
+	snap       synth.SnapScale
+	ccMap      synth.CCMap
+	notes      []float64 // the frequency of every midi note, from -tuning or -scl
+	bpm        float64
+}
+
+// parsePatch reads the sound from the flags as the command line and any preset left them
+func parsePatch() patch {
+	wave, err := synth.ParseWaveform(*waveFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var wavetable synth.Wavetable
+	if *tableFlag != "" {
+		if wavetable, err = synth.ReadWavetable(*tableFlag); err != nil {
+			log.Fatal(fmt.Errorf("Error reading wavetable: %s", err.Error()))
+		}
+		wave = synth.Sine // the table takes the waveform's place
+	}
+	harmonics, err := synth.ParseHarmonics(*additiveFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(harmonics) > 0 {
+		wave = synth.Sine // as do the harmonics
+	}
+	samples, err := synth.ReadSampleMap(*sampleMap, *rateFlag)
+	if err != nil {
+		log.Fatal(fmt.Errorf("Error reading samples: %s", err.Error()))
+	}
+	subWave, err := synth.ParseWaveform(*subWaveFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if subWave != synth.Sine && subWave != synth.Square {
+		log.Fatal(fmt.Errorf("Sub oscillator must be sine or square, got %s", *subWaveFlag))
+	}
+	velCurve, err := synth.ParseVelocityCurve(*velFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	aftertouch, err := synth.ParseAftertouchTarget(*touchFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	glide, err := synth.ParseGlideMode(*glideMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *legatoGlide {
+		glide = synth.GlideLegato
+	}
+	priority, err := synth.ParseNotePriority(*priorityFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	voicePan, err := synth.ParseVoicePan(*voicePanFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	arp, err := synth.ParseArpPattern(*arpFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	delayNote, err := parseNoteLength(*delaySync)
+	if err != nil {
+		log.Fatal(err)
+	}
+	routing, err := synth.ParseFilterRouting(*routingFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	snap, err := synth.ParseSnapScale(*snapFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ccMap, err := synth.ParseCCMap(*ccMapFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *tuningFlag <= 0 {
+		log.Fatal(fmt.Errorf("Tuning must be positive, got %g", *tuningFlag))
+	}
+	notes := synth.BuildNoteMap(*tuningFlag)
+	if *sclFlag != "" {
+		if *sclRootFlag < 0 || *sclRootFlag > 127 {
+			log.Fatal(fmt.Errorf("Scale root must be a midi note, 0 to 127, got %d", *sclRootFlag))
+		}
+		scale, err := synth.ReadScala(*sclFlag)
+		if err != nil {
+			log.Fatal(fmt.Errorf("Error reading scale: %s", err.Error()))
+		}
+		notes = scale.NoteMap(*sclRootFlag, notes[*sclRootFlag])
+	}
+	bpm, given := *bpmFlag, false // -click sets the tempo when -bpm isn't set, even to the default
+	flag.Visit(func(f *flag.Flag) { given = given || f.Name == "bpm" })
+	if !given && *clickFlag > 0 {
+		bpm = *clickFlag
+	}
+	return patch{
+		wave:       wave,
+		wavetable:  wavetable,
+		harmonics:  harmonics,
+		samples:    samples,
+		subWave:    subWave,
+		velCurve:   velCurve,
+		aftertouch: aftertouch,
+		glide:      glide,
+		priority:   priority,
+		voicePan:   voicePan,
+		arp:        arp,
+		delayNote:  delayNote,
+		routing:    routing,
+		env: synth.Envelope{
+			Attack:  *attackFlag,
+			Decay:   *decayFlag,
+			Sustain: math.Max(0, math.Min(1, *sustainFlag)),
+			Release: *releaseFlag,
+		},
+		filterEnv: synth.Envelope{
+			Attack:  *fAttackFlag,
+			Decay:   *fDecayFlag,
+			Sustain: math.Max(0, math.Min(1, *fSustainFlag)),
+			Release: *fReleaseFlag,
+		},
+		snap:  snap,
+		ccMap: ccMap,
+		notes: notes,
+		bpm:   math.Max(1, bpm),
+	}
+}
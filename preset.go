@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+// Preset is a patch, every setting that shapes the sound, saved as JSON so it can be shared
+type Preset struct {
+	Wave      string  `json:"wave"`
+	Wavetable string  `json:"wavetable"`
+	Additive  string  `json:"additive"`
+	BandLimit bool    `json:"bandlimit"`
+	Analog    float64 `json:"analog"`
+	Sync2     float64 `json:"sync2"`
+	SampleMap string  `json:"samplemap"`
+	SubWave   string  `json:"subwave"`
+	Sub       float64 `json:"sub"`
+	Detune    float64 `json:"detune"`
+	Unison    int     `json:"unison"`
+	Spread    float64 `json:"unisonspread"`
+
+	Attack  float64 `json:"attack"`
+	Decay   float64 `json:"decay"`
+	Sustain float64 `json:"sustain"`
+	Release float64 `json:"release"`
+	Voices  int     `json:"voices"`
+	Poly    bool    `json:"poly"`
+
+	VoicePan  string  `json:"voicepan"`
+	Priority  string  `json:"priority"`
+	Retrigger bool    `json:"retrigger"`
+	StealFade float64 `json:"stealfade"`
+
+	VelocityCurve string  `json:"velcurve"`
+	Aftertouch    string  `json:"aftertouch"`
+	Glide         float64 `json:"glide"`
+	GlideMode     string  `json:"glidemode"`
+	LegatoGlide   bool    `json:"legatoglide"`
+	BendRange     float64 `json:"bendrange"`
+	VibratoRate   float64 `json:"vibratorate"`
+	CCMap         string  `json:"ccmap"`
+	CCSlew        float64 `json:"ccslew"`
+
+	Cutoff    float64 `json:"cutoff"`
+	Resonance float64 `json:"resonance"`
+	Routing   string  `json:"filterrouting"`
+	HPCutoff  float64 `json:"hpcutoff"`
+	HPRes     float64 `json:"hpresonance"`
+	VelMod    float64 `json:"velmod"`
+	KeyTrack  float64 `json:"keytrack"`
+	VelEnv    float64 `json:"velenv"`
+	VelDecay  bool    `json:"velenvdecay"`
+
+	FilterAttack    float64 `json:"fattack"`
+	FilterDecay     float64 `json:"fdecay"`
+	FilterSustain   float64 `json:"fsustain"`
+	FilterRelease   float64 `json:"frelease"`
+	FilterEnvAmount float64 `json:"fenvamount"`
+
+	PulseWidth   float64 `json:"pulsewidth"`
+	PWMRate      float64 `json:"pwmrate"`
+	PWMDepth     float64 `json:"pwmdepth"`
+	TremoloRate  float64 `json:"tremolorate"`
+	TremoloDepth float64 `json:"tremolodepth"`
+	RingMod      float64 `json:"ringmod"`
+	RingRatio    float64 `json:"ringratio"`
+	FM           bool    `json:"fm"`
+	FMRatio      float64 `json:"fmratio"`
+	FMIndex      float64 `json:"fmindex"`
+
+	Arp         string  `json:"arp"`
+	BPM         float64 `json:"bpm"`
+	ArpDivision float64 `json:"arpdiv"`
+	ArpOctaves  int     `json:"arpoctaves"`
+	Latch       bool    `json:"latch"`
+
+	Drive           float64 `json:"drive"`
+	Bits            int     `json:"bits"`
+	Downsample      int     `json:"downsample"`
+	PhaserRate      float64 `json:"phaserrate"`
+	PhaserDepth     float64 `json:"phaserdepth"`
+	PhaserStages    int     `json:"phaserstages"`
+	PhaserMix       float64 `json:"phasermix"`
+	FlangerRate     float64 `json:"flangerrate"`
+	FlangerDepth    float64 `json:"flangerdepth"`
+	FlangerFeedback float64 `json:"flangerfeedback"`
+	FlangerMix      float64 `json:"flangermix"`
+	ChorusRate      float64 `json:"chorusrate"`
+	ChorusDepth     float64 `json:"chorusdepth"`
+	ChorusMix       float64 `json:"chorusmix"`
+	DelayTime       float64 `json:"delaytime"`
+	DelaySync       string  `json:"delaysync"`
+	DelayFeedback   float64 `json:"delayfeedback"`
+	DelayMix        float64 `json:"delaymix"`
+	ReverbSize      float64 `json:"reverbsize"`
+	ReverbDamp      float64 `json:"reverbdamp"`
+	ReverbMix       float64 `json:"reverbmix"`
+	EQFreq          float64 `json:"eqfreq"`
+	EQGain          float64 `json:"eqgain"`
+	EQQ             float64 `json:"eqq"`
+	CompRatio       float64 `json:"compratio"`
+	CompThreshold   float64 `json:"compthreshold"`
+	CompAttack      float64 `json:"compattack"`
+	CompRelease     float64 `json:"comprelease"`
+	CompMakeup      float64 `json:"compmakeup"`
+	Volume          float64 `json:"volume"`
+	Pan             float64 `json:"pan"`
+	Width           float64 `json:"width"`
+	Haas            float64 `json:"haas"`
+	DCBlock         bool    `json:"dcblock"`
+	Limiter         bool    `json:"limiter"`
+	Threshold       float64 `json:"threshold"`
+	Gate            level   `json:"gate"`
+
+	Tuning    float64 `json:"tuning"`
+	Scale     string  `json:"scl"`
+	ScaleRoot int64   `json:"sclroot"`
+	Transpose int64   `json:"transpose"`
+	Snap      string  `json:"snap"`
+	Octave    int64   `json:"octave"`
+}
+
+// level is a level in dBFS, written "-inf" in JSON when it's silence, which a JSON number can't be
+type level float64
+
+// MarshalJSON writes the level as a number, or "-inf"
+func (l level) MarshalJSON() ([]byte, error) {
+	if math.IsInf(float64(l), -1) {
+		return []byte(`"-inf"`), nil
+	}
+	return json.Marshal(float64(l))
+}
+
+// UnmarshalJSON reads the level from a number, or a string like "-inf"
+func (l *level) UnmarshalJSON(data []byte) error {
+	var f float64
+	if err := json.Unmarshal(data, &f); err == nil {
+		*l = level(f)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("level %q: %s", s, err.Error())
+	}
+	*l = level(f)
+	return nil
+}
+
+// fields maps the preset onto the flags that set each of its values
+func (p *Preset) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"wave": &p.Wave, "wavetable": &p.Wavetable, "additive": &p.Additive, "bandlimit": &p.BandLimit, "analog": &p.Analog, "sync2": &p.Sync2, "samplemap": &p.SampleMap, "subwave": &p.SubWave, "sub": &p.Sub, "detune": &p.Detune,
+		"unison": &p.Unison, "unisonspread": &p.Spread,
+		"attack": &p.Attack, "decay": &p.Decay, "sustain": &p.Sustain, "release": &p.Release, "voices": &p.Voices, "poly": &p.Poly, "voicepan": &p.VoicePan,
+		"priority": &p.Priority, "retrigger": &p.Retrigger, "stealfade": &p.StealFade,
+		"velcurve": &p.VelocityCurve, "aftertouch": &p.Aftertouch, "glide": &p.Glide, "glidemode": &p.GlideMode, "legatoglide": &p.LegatoGlide,
+		"bendrange": &p.BendRange, "vibratorate": &p.VibratoRate, "ccmap": &p.CCMap, "ccslew": &p.CCSlew,
+		"cutoff": &p.Cutoff, "resonance": &p.Resonance, "filterrouting": &p.Routing, "hpcutoff": &p.HPCutoff, "hpresonance": &p.HPRes, "velmod": &p.VelMod, "keytrack": &p.KeyTrack, "velenv": &p.VelEnv, "velenvdecay": &p.VelDecay,
+		"fattack": &p.FilterAttack, "fdecay": &p.FilterDecay, "fsustain": &p.FilterSustain, "frelease": &p.FilterRelease,
+		"fenvamount": &p.FilterEnvAmount,
+		"pulsewidth": &p.PulseWidth, "pwmrate": &p.PWMRate, "pwmdepth": &p.PWMDepth,
+		"tremolorate": &p.TremoloRate, "tremolodepth": &p.TremoloDepth, "ringmod": &p.RingMod, "ringratio": &p.RingRatio,
+		"fm": &p.FM, "fmratio": &p.FMRatio, "fmindex": &p.FMIndex,
+		"arp": &p.Arp, "bpm": &p.BPM, "arpdiv": &p.ArpDivision, "arpoctaves": &p.ArpOctaves, "latch": &p.Latch,
+		"drive": &p.Drive, "bits": &p.Bits, "downsample": &p.Downsample,
+		"phaserrate": &p.PhaserRate, "phaserdepth": &p.PhaserDepth, "phaserstages": &p.PhaserStages, "phasermix": &p.PhaserMix,
+		"flangerrate": &p.FlangerRate, "flangerdepth": &p.FlangerDepth, "flangerfeedback": &p.FlangerFeedback, "flangermix": &p.FlangerMix,
+		"chorusrate": &p.ChorusRate, "chorusdepth": &p.ChorusDepth, "chorusmix": &p.ChorusMix,
+		"delaytime": &p.DelayTime, "delaysync": &p.DelaySync, "delayfeedback": &p.DelayFeedback, "delaymix": &p.DelayMix,
+		"reverbsize": &p.ReverbSize, "reverbdamp": &p.ReverbDamp, "reverbmix": &p.ReverbMix,
+		"eqfreq": &p.EQFreq, "eqgain": &p.EQGain, "eqq": &p.EQQ,
+		"compratio": &p.CompRatio, "compthreshold": &p.CompThreshold, "compattack": &p.CompAttack, "comprelease": &p.CompRelease, "compmakeup": &p.CompMakeup,
+		"volume": &p.Volume, "pan": &p.Pan, "width": &p.Width, "haas": &p.Haas,
+		"dcblock": &p.DCBlock, "limiter": &p.Limiter, "threshold": &p.Threshold, "gate": &p.Gate,
+		"tuning": &p.Tuning, "scl": &p.Scale, "sclroot": &p.ScaleRoot, "transpose": &p.Transpose, "octave": &p.Octave,
+		"snap": &p.Snap,
+	}
+}
+
+// presetFromFlags captures the current value of every flag a preset covers
+func presetFromFlags() *Preset {
+	p := &Preset{}
+	for name, field := range p.fields() {
+		value := flag.Lookup(name).Value.(flag.Getter).Get()
+		switch field := field.(type) {
+		case *string:
+			*field = value.(string)
+		case *bool:
+			*field = value.(bool)
+		case *float64:
+			*field = value.(float64)
+		case *level:
+			*field = level(value.(float64))
+		case *int:
+			*field = value.(int)
+		case *int64:
+			*field = value.(int64)
+		}
+	}
+	return p
+}
+
+// loadPreset reads a preset into the flags, leaving any given on the command line as they are
+func loadPreset(path string) error {
+	return loadPresetOver(presetFromFlags(), path) // anything the file leaves out keeps its current value
+}
+
+// loadPresetOver reads a preset into the flags, taking anything the file leaves out from base
+func loadPresetOver(base *Preset, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	p := *base
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("parsing %s: %s", path, err.Error())
+	}
+	if err := applyPreset(&p); err != nil {
+		return fmt.Errorf("%s in %s", err.Error(), path)
+	}
+	return nil
+}
+
+// explicit holds the flags given on the command line, taken before the first preset
+var explicit map[string]bool
+
+// applyPreset sets the flags to the preset, besides those given on the command line
+func applyPreset(p *Preset) error {
+	if explicit == nil {
+		explicit = map[string]bool{}
+		flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	}
+	for name, field := range p.fields() {
+		if explicit[name] {
+			continue
+		}
+		var value string
+		switch field := field.(type) {
+		case *string:
+			value = *field
+		case *bool:
+			value = fmt.Sprint(*field)
+		case *float64:
+			value = fmt.Sprint(*field)
+		case *level:
+			value = fmt.Sprint(float64(*field))
+		case *int:
+			value = fmt.Sprint(*field)
+		case *int64:
+			value = fmt.Sprint(*field)
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+// savePreset writes the current settings out as a preset
+func savePreset(path string) error {
+	data, err := json.MarshalIndent(presetFromFlags(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// keepFlags puts every flag a preset covers, and what counts as given, back once the test is done
+func keepFlags(t *testing.T, given map[string]bool) {
+	values := map[string]string{}
+	for name := range (&Preset{}).fields() {
+		values[name] = flag.Lookup(name).Value.String()
+	}
+	was := explicit
+	explicit = given
+	t.Cleanup(func() {
+		for name, value := range values {
+			flag.Lookup(name).Value.Set(value)
+		}
+		explicit = was
+	})
+}
+
+// setFlag sets a flag the way a preset does, without it counting as given on the command line
+func setFlag(t *testing.T, name, value string) {
+	if err := flag.Lookup(name).Value.Set(value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPresetRoundTrip(t *testing.T) {
+	keepFlags(t, map[string]bool{})
+	saved := map[string]string{"wave": "saw", "cutoff": "1234.5", "voices": "3", "transpose": "-7", "fm": "true", "ccmap": "74=cutoff", "gate": "-Inf"}
+	for name, value := range saved {
+		setFlag(t, name, value)
+	}
+	path := filepath.Join(t.TempDir(), "preset.json")
+	if err := savePreset(path); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"wave": "sine", "cutoff": "20000", "voices": "8", "transpose": "0", "fm": "false", "ccmap": "", "gate": "-6"} {
+		setFlag(t, name, value)
+	}
+	if err := loadPreset(path); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range saved {
+		if got := flag.Lookup(name).Value.String(); got != want {
+			t.Errorf("-%s loaded as %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPresetEveryField(t *testing.T) {
+	keepFlags(t, map[string]bool{})
+	saved := map[string]string{}
+	for name, field := range (&Preset{}).fields() {
+		value := flag.Lookup(name).Value.(flag.Getter).Get()
+		switch field.(type) { // something other than what it is now
+		case *string:
+			saved[name] = "not " + value.(string)
+		case *bool:
+			saved[name] = fmt.Sprint(!value.(bool))
+		case *float64, *level:
+			saved[name] = fmt.Sprint(math.Max(-60, value.(float64)) + 1.5)
+		case *int:
+			saved[name] = fmt.Sprint(value.(int) + 3)
+		case *int64:
+			saved[name] = fmt.Sprint(value.(int64) + 3)
+		default:
+			t.Fatalf("-%s is a %T, which presets don't read or write", name, field)
+		}
+	}
+	was := map[string]string{}
+	for name, value := range saved {
+		was[name] = flag.Lookup(name).Value.String()
+		setFlag(t, name, value)
+	}
+	path := filepath.Join(t.TempDir(), "preset.json")
+	if err := savePreset(path); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range was {
+		setFlag(t, name, value)
+	}
+	if err := loadPreset(path); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range saved {
+		if got := flag.Lookup(name).Value.String(); got != want {
+			t.Errorf("-%s loaded as %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPresetKeepsCommandLine(t *testing.T) {
+	keepFlags(t, map[string]bool{"resonance": true}) // given on the command line
+	setFlag(t, "resonance", "0.5")
+	path := filepath.Join(t.TempDir(), "preset.json")
+	if err := savePreset(path); err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, "resonance", "0.25")
+	if err := loadPreset(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := flag.Lookup("resonance").Value.String(); got != "0.25" {
+		t.Errorf("-resonance from the command line became %s loading a preset", got)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rakyll/portmidi"
+)
+
+const (
+	reconnectFirst = 500 * time.Millisecond // wait before first looking for a lost device
+	reconnectMax   = 5 * time.Second        // the longest the wait backs off to
+)
+
+// reconnector reopens midi inputs by name once they are plugged back in, for -reconnect
+type reconnector struct {
+	mu      sync.Mutex // held while looking, when the inputs can't be polled
+	inputs  []*reconnectingInput
+	waiting bool   // a goroutine is looking for the lost inputs
+	cards   []byte // /proc/asound/cards when it last looked
+}
+
+// reconnectingInput is one of the reconnector's inputs, taking a device's place in makeMidiHandler
+type reconnectingInput struct {
+	r      *reconnector
+	name   string
+	stream *portmidi.Stream // nil while it's unplugged
+}
+
+// add hands the open stream for the named device over to the reconnector, which closes it from then on
+func (r *reconnector) add(name string, stream *portmidi.Stream) *reconnectingInput {
+	in := &reconnectingInput{r: r, name: name, stream: stream}
+	r.inputs = append(r.inputs, in)
+	return in
+}
+
+// Close closes every input that's open
+func (r *reconnector) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, in := range r.inputs {
+		in.close()
+	}
+	return nil
+}
+
+// lose closes a failed input and starts looking for it, with the lock held
+func (r *reconnector) lose(in *reconnectingInput, err error) {
+	log.Printf("Lost midi device %s, waiting for it to come back: %s", in.name, err.Error())
+	in.close()
+	if !r.waiting {
+		r.waiting = true
+		r.cards = soundCards()
+		go r.watch()
+	}
+}
+
+// watch looks for the lost inputs until they're all back, on a backoff and as soon as the sound cards change
+func (r *reconnector) watch() {
+	r.mu.Lock()
+	cards := r.cards
+	r.mu.Unlock()
+	wait, tried := reconnectFirst, time.Now()
+	for {
+		time.Sleep(reconnectFirst)
+		now := soundCards()
+		changed := now != nil && string(now) != string(cards) // only a hint, not every device is a card
+		if !changed && time.Since(tried) < wait {
+			continue
+		}
+		if wait *= 2; wait > reconnectMax {
+			wait = reconnectMax
+		}
+		tried, cards = time.Now(), now
+		r.mu.Lock()
+		r.cards = now
+		if r.reopen() {
+			r.waiting = false
+			r.mu.Unlock()
+			return
+		}
+		r.mu.Unlock()
+	}
+}
+
+// reopen starts portmidi afresh and opens every input by name, reporting whether they're all back
+func (r *reconnector) reopen() bool {
+	for _, in := range r.inputs {
+		in.close()
+	}
+	portmidi.Terminate()
+	if err := portmidi.Initialize(); err != nil {
+		log.Printf("Error restarting midi: %s", err.Error())
+		return false
+	}
+	found := true
+	for _, in := range r.inputs {
+		id, ok := inputByName(in.name)
+		if !ok {
+			found = false
+			continue
+		}
+		stream, err := portmidi.NewInputStream(id, 64)
+		if err != nil {
+			log.Printf("Error reopening midi device %s: %s", in.name, err.Error())
+			found = false
+			continue
+		}
+		in.stream = stream
+	}
+	if found {
+		log.Printf("Midi devices back")
+	}
+	return found
+}
+
+// Poll reports whether the input has events waiting, never while it's unplugged or being looked for
+func (in *reconnectingInput) Poll() (bool, error) {
+	if !in.r.mu.TryLock() { // looking for devices, which can take a while, don't hold up the audio
+		return false, nil
+	}
+	defer in.r.mu.Unlock()
+	if in.stream == nil {
+		return false, nil
+	}
+	res, err := in.stream.Poll()
+	if err != nil {
+		in.r.lose(in, err)
+		return false, nil
+	}
+	return res, nil
+}
+
+// Read reads the events waiting, none while the input is unplugged
+func (in *reconnectingInput) Read(max int) ([]portmidi.Event, error) {
+	if !in.r.mu.TryLock() {
+		return nil, nil
+	}
+	defer in.r.mu.Unlock()
+	if in.stream == nil {
+		return nil, nil
+	}
+	events, err := in.stream.Read(max)
+	if err != nil {
+		in.r.lose(in, err)
+		return nil, nil
+	}
+	return events, nil
+}
+
+// Listen is the stream's own channel, nil while it's unplugged
+func (in *reconnectingInput) Listen() <-chan portmidi.Event {
+	in.r.mu.Lock()
+	defer in.r.mu.Unlock()
+	if in.stream == nil {
+		return nil
+	}
+	return in.stream.Listen()
+}
+
+// Close closes the input, if it's open
+func (in *reconnectingInput) Close() error {
+	in.r.mu.Lock()
+	defer in.r.mu.Unlock()
+	return in.close()
+}
+
+func (in *reconnectingInput) close() error {
+	if in.stream == nil {
+		return nil
+	}
+	err := in.stream.Close()
+	in.stream = nil
+	return err
+}
+
+// inputByName finds an input device by the name portmidi gives it
+func inputByName(name string) (portmidi.DeviceID, bool) {
+	for i := 0; i < portmidi.CountDevices(); i++ {
+		info := portmidi.Info(portmidi.DeviceID(i))
+		if info.IsInputAvailable && info.Name == name {
+			return portmidi.DeviceID(i), true
+		}
+	}
+	return 0, false
+}
+
+// soundCards is the list of sound cards, USB midi devices among them, or nil where it can't be read
+func soundCards() []byte {
+	cards, err := os.ReadFile("/proc/asound/cards")
+	if err != nil {
+		return nil
+	}
+	return cards
+}
@@ -0,0 +1,57 @@
+package synth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxHarmonics bounds the additive oscillator's cost, a sine a harmonic for every voice every sample
+const maxHarmonics = 64
+
+// Harmonics are the amplitudes of a tone's harmonics, fundamental first
+type Harmonics []float64
+
+// ParseHarmonics reads amplitudes like 1,0.5,0.33,0.25, scaled to stay within full scale
+func ParseHarmonics(s string) (Harmonics, error) {
+	h := Harmonics{}
+	if strings.TrimSpace(s) == "" {
+		return h, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) > maxHarmonics {
+		return nil, fmt.Errorf("%d harmonics is too many, up to %d", len(parts), maxHarmonics)
+	}
+	total := 0.0
+	for _, part := range parts {
+		amp, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a harmonic amplitude", part)
+		}
+		h = append(h, amp)
+		if amp < 0 {
+			amp = -amp
+		}
+		total += amp
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("harmonics %q are all silent", s)
+	}
+	for i := range h {
+		h[i] /= total
+	}
+	return h, nil
+}
+
+// oscillate sums the harmonics at phase, leaving out any above nyquist
+func (h Harmonics) oscillate(phase, freq, nyquist float64) float64 {
+	sum := 0.0
+	for i, amp := range h {
+		n := float64(i + 1)
+		if n*freq >= nyquist {
+			break
+		}
+		sum += amp * sine(n*phase)
+	}
+	return sum
+}
@@ -0,0 +1,37 @@
+package synth
+
+import "fmt"
+
+// AftertouchTarget is what pressing harder on held keys changes
+type AftertouchTarget int
+
+const (
+	AftertouchOff AftertouchTarget = iota
+	AftertouchAmplitude
+	AftertouchCutoff
+)
+
+var aftertouchTargetNames = map[AftertouchTarget]string{
+	AftertouchOff:       "off",
+	AftertouchAmplitude: "amplitude",
+	AftertouchCutoff:    "cutoff",
+}
+
+func (t AftertouchTarget) String() string {
+	return aftertouchTargetNames[t]
+}
+
+// ParseAftertouchTarget looks up a target by its name: off, amplitude or cutoff
+func ParseAftertouchTarget(name string) (AftertouchTarget, error) {
+	for t, n := range aftertouchTargetNames {
+		if n == name {
+			return t, nil
+		}
+	}
+	return AftertouchOff, fmt.Errorf("unknown aftertouch target %q", name)
+}
+
+const (
+	aftertouchBoost   = 0.5 // most a note's loudness can grow with full pressure
+	aftertouchOctaves = 2   // furthest full pressure opens the filter
+)
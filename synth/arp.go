@@ -0,0 +1,166 @@
+package synth
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ArpPattern is the order the arpeggiator steps through the held keys in
+type ArpPattern int
+
+const (
+	ArpOff ArpPattern = iota
+	ArpUp
+	ArpDown
+	ArpUpDown
+	ArpRandom
+)
+
+var arpPatternNames = map[ArpPattern]string{
+	ArpOff:    "off",
+	ArpUp:     "up",
+	ArpDown:   "down",
+	ArpUpDown: "updown",
+	ArpRandom: "random",
+}
+
+func (p ArpPattern) String() string {
+	return arpPatternNames[p]
+}
+
+// ParseArpPattern looks up a pattern by its name: off, up, down, updown or random
+func ParseArpPattern(name string) (ArpPattern, error) {
+	for p, n := range arpPatternNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return ArpOff, fmt.Errorf("unknown arpeggiator pattern %q", name)
+}
+
+// arpGate is the fraction of each step its note is held for
+const arpGate = 0.5
+
+// arpeggiator plays the held keys one at a time in a pattern, stepping with the Clock
+type arpeggiator struct {
+	pool    *VoicePool
+	held    []heldKey  // keys down, lowest first
+	step    int        // steps played since the keys were first pressed
+	steps   clockSteps // where the clock has got to
+	elapsed float64    // seconds into the current step
+	fresh   bool       // the first key's just gone down, and plays straight away
+	playing int64      // note currently sounding, -1 for none
+}
+
+func newArpeggiator(pool *VoicePool) *arpeggiator {
+	return &arpeggiator{pool: pool, playing: -1}
+}
+
+func (a *arpeggiator) noteOn(note int64, velocity float64) {
+	if len(a.held) == 0 {
+		a.step, a.fresh = 0, true
+	}
+	a.held = append(a.held, heldKey{note: note, velocity: velocity})
+	sort.SliceStable(a.held, func(i, j int) bool { return a.held[i].note < a.held[j].note })
+}
+
+func (a *arpeggiator) noteOff(note int64) {
+	for i, k := range a.held {
+		if k.note == note {
+			a.held = append(a.held[:i], a.held[i+1:]...)
+			return
+		}
+	}
+}
+
+// clear forgets every held key, the note sounding is released as usual
+func (a *arpeggiator) clear() {
+	a.held = a.held[:0]
+}
+
+// advance moves the arpeggiator on by deltaT, stepping every params.ArpDivision of a whole note
+func (a *arpeggiator) advance(params *Params, clock *Clock, deltaT float64) {
+	stepTime := 1.0
+	if bpm := clock.BPM(); bpm > 0 && params.ArpDivision > 0 {
+		stepTime = 60 / bpm * 4 / params.ArpDivision
+	}
+	_, stepped := a.steps.next(clock, params.ArpDivision)
+	if a.playing >= 0 && a.elapsed >= stepTime*arpGate {
+		a.pool.NoteOff(a.playing)
+		a.playing = -1
+	}
+	if (stepped || a.fresh) && !params.Sync {
+		a.elapsed = 0
+		a.play(params)
+	}
+	a.elapsed += deltaT
+}
+
+// tick steps the arpeggiator along with a midi clock, given the ticks counted since it started
+func (a *arpeggiator) tick(params *Params, ticks int) {
+	perStep := int(math.Round(clockTicksPerWhole / params.ArpDivision))
+	if perStep < 1 {
+		perStep = 1
+	}
+	if ticks%perStep == 0 {
+		a.elapsed = 0
+		a.play(params)
+	}
+}
+
+// restart goes back to the start of the pattern, for a midi clock's START
+func (a *arpeggiator) restart() {
+	a.step = 0
+}
+
+// play moves on to the next step's note
+func (a *arpeggiator) play(params *Params) {
+	a.fresh = false
+	if a.playing >= 0 { // a step shorter than the gate
+		a.pool.NoteOff(a.playing)
+		a.playing = -1
+	}
+	if k, ok := a.next(params); ok {
+		a.pool.NoteOn(k.note, k.velocity)
+		a.playing = k.note
+	}
+	a.step++
+}
+
+// next is the key the pattern lands on this step, across params.ArpOctaves octaves
+func (a *arpeggiator) next(params *Params) (heldKey, bool) {
+	octaves := int64(params.ArpOctaves)
+	if octaves < 1 {
+		octaves = 1
+	}
+	sequence := []heldKey{}
+	for octave := int64(0); octave < octaves; octave++ {
+		for _, k := range a.held {
+			if note := k.note + 12*octave; note <= 127 {
+				sequence = append(sequence, heldKey{note: note, velocity: k.velocity})
+			}
+		}
+	}
+	n := len(sequence)
+	if n == 0 {
+		return heldKey{}, false
+	}
+	switch params.Arp {
+	case ArpDown:
+		return sequence[n-1-a.step%n], true
+	case ArpUpDown:
+		if n == 1 {
+			return sequence[0], true
+		}
+		i := a.step % (2*n - 2) // up then back down, without repeating the top or bottom
+		if i >= n {
+			i = 2*n - 2 - i
+		}
+		return sequence[i], true
+	case ArpRandom:
+		return sequence[a.pool.rng.Intn(n)], true
+	default:
+		return sequence[a.step%n], true
+	}
+}
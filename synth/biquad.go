@@ -0,0 +1,52 @@
+package synth
+
+import "math"
+
+// BiquadKind is the response a Biquad is designed for
+type BiquadKind int
+
+const (
+	BiquadPeaking  BiquadKind = iota // boosts or cuts a band around the frequency by the gain
+	BiquadLowPass                    // passes below the frequency
+	BiquadHighPass                   // passes above it
+	BiquadBandPass                   // passes a band around it, with a peak of 0dB
+)
+
+// Biquad is a second order filter from Robert Bristow-Johnson's Audio EQ Cookbook
+type Biquad struct {
+	b0, b1, b2, a1, a2 float64 // normalized so a0 is 1
+	x1, x2, y1, y2     float64 // the last two inputs and outputs
+}
+
+// NewBiquad designs a filter of the kind at freq Hz, gain in dB only for peaking
+func NewBiquad(ac *AudioContext, kind BiquadKind, freq, q, gain float64) *Biquad {
+	nyquist := float64(ac.SampleRate) / 2
+	w := 2 * math.Pi * clamp(freq, 1, nyquist*0.99) / float64(ac.SampleRate)
+	alpha := math.Sin(w) / (2 * math.Max(q, 0.01))
+	cos := math.Cos(w)
+	var b0, b1, b2, a0, a1, a2 float64
+	switch kind {
+	case BiquadPeaking:
+		a := math.Pow(10, gain/40)
+		b0, b1, b2 = 1+alpha*a, -2*cos, 1-alpha*a
+		a0, a1, a2 = 1+alpha/a, -2*cos, 1-alpha/a
+	case BiquadLowPass:
+		b0, b1, b2 = (1-cos)/2, 1-cos, (1-cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case BiquadHighPass:
+		b0, b1, b2 = (1+cos)/2, -(1 + cos), (1+cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case BiquadBandPass:
+		b0, b1, b2 = alpha, 0, -alpha
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	}
+	return &Biquad{b0: b0 / a0, b1: b1 / a0, b2: b2 / a0, a1: a1 / a0, a2: a2 / a0}
+}
+
+// Process filters one sample
+func (b *Biquad) Process(sample float64) float64 {
+	out := b.b0*sample + b.b1*b.x1 + b.b2*b.x2 - b.a1*b.y1 - b.a2*b.y2
+	b.x2, b.x1 = b.x1, sample
+	b.y2, b.y1 = b.y1, out
+	return out
+}
@@ -0,0 +1,41 @@
+package synth
+
+import "math"
+
+// Bitcrusher quantizes each sample to Bits and holds it for Downsample samples
+type Bitcrusher struct {
+	Bits       int // 1 to 16
+	Downsample int // samples to hold each one for
+
+	held    float64
+	counter int
+}
+
+// NewBitcrusher makes a bitcrusher, limiting bits to 1 to 16 and the downsample to at least 1
+func NewBitcrusher(bits, downsample int) *Bitcrusher {
+	if bits < 1 {
+		bits = 1
+	} else if bits > 16 {
+		bits = 16
+	}
+	if downsample < 1 {
+		downsample = 1
+	}
+	return &Bitcrusher{Bits: bits, Downsample: downsample}
+}
+
+// Process crushes one sample
+func (b *Bitcrusher) Process(sample float64) float64 {
+	if b.counter == 0 {
+		b.held = sample
+		if b.Bits < 16 { // at 16 the output's own resolution does the quantizing
+			steps := math.Pow(2, float64(b.Bits-1)) // per side of zero
+			b.held = math.Round(sample*steps) / steps
+		}
+	}
+	b.counter++
+	if b.counter >= b.Downsample {
+		b.counter = 0
+	}
+	return b.held
+}
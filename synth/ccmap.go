@@ -0,0 +1,182 @@
+package synth
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CCTarget is a setting a controller's knob or slider can be assigned to
+type CCTarget int
+
+const (
+	CCCutoff CCTarget = iota
+	CCResonance
+	CCVibrato
+	CCVolume
+	CCPan
+	CCDrive
+	CCDetune
+	CCGlide
+	CCSub
+	CCPulseWidth
+	CCPWMDepth
+	CCTremoloRate
+	CCTremoloDepth
+	CCAttack
+	CCDecay
+	CCSustain
+	CCRelease
+	CCSyncRatio
+)
+
+var ccTargetNames = map[CCTarget]string{
+	CCCutoff:       "cutoff",
+	CCResonance:    "resonance",
+	CCVibrato:      "vibrato",
+	CCVolume:       "volume",
+	CCPan:          "pan",
+	CCDrive:        "drive",
+	CCDetune:       "detune",
+	CCGlide:        "glide",
+	CCSub:          "sub",
+	CCPulseWidth:   "pulsewidth",
+	CCPWMDepth:     "pwmdepth",
+	CCTremoloRate:  "tremolorate",
+	CCTremoloDepth: "tremolodepth",
+	CCAttack:       "attack",
+	CCDecay:        "decay",
+	CCSustain:      "sustain",
+	CCRelease:      "release",
+	CCSyncRatio:    "syncratio",
+}
+
+func (t CCTarget) String() string {
+	return ccTargetNames[t]
+}
+
+// set moves the target to a controller value, 0 to 1, scaled across its range
+func (t CCTarget) set(params *Params, pool *VoicePool, value float64) {
+	env := pool.envelope
+	switch t {
+	case CCAttack: // times are squared so the short end, where it matters most, gets more of the travel
+		env.Attack = 2 * value * value
+	case CCDecay:
+		env.Decay = 2 * value * value
+	case CCSustain:
+		env.Sustain = value
+	case CCRelease:
+		env.Release = 4 * value * value
+	}
+	if env != pool.envelope {
+		pool.SetEnvelope(env)
+	}
+	switch t {
+	case CCCutoff:
+		params.Cutoff = 20 * math.Pow(1000, value) // 20Hz to 20kHz, evenly in octaves
+	case CCResonance:
+		params.Resonance = value
+	case CCVolume:
+		params.Volume = value
+	case CCPan:
+		params.Pan = 2*value - 1
+	case CCDrive:
+		params.Drive = 10 * value
+	case CCDetune:
+		params.Detune = 50 * value
+	case CCGlide:
+		params.Glide = 1000 * value
+	case CCSub:
+		params.Sub = value
+	case CCPulseWidth:
+		params.PulseWidth = minPulseWidth + (maxPulseWidth-minPulseWidth)*value
+	case CCPWMDepth:
+		params.PWMDepth = 0.45 * value
+	case CCTremoloRate:
+		params.TremoloRate = 20 * value
+	case CCTremoloDepth:
+		params.TremoloDepth = value
+	case CCSyncRatio: // swept up from the master's own pitch, the classic sync sweep
+		params.SyncRatio = 1 + (maxSyncRatio-1)*value
+	}
+}
+
+// smoothed reports whether the target glides to new values, everything but the envelope
+func (t CCTarget) smoothed() bool {
+	switch t {
+	case CCAttack, CCDecay, CCSustain, CCRelease:
+		return false
+	}
+	return true
+}
+
+// slew is a smoothed controller's value, 0 to 1, gliding to where the knob was last moved
+type slew struct {
+	value  float64
+	target float64
+}
+
+// advance moves the value towards the target over tau seconds, reporting whether it moved
+func (s *slew) advance(deltaT, tau float64) bool {
+	if s.value == s.target {
+		return false
+	}
+	s.value = s.target + (s.value-s.target)*math.Exp(-deltaT/tau)
+	if math.Abs(s.value-s.target) < 1e-4 { // close enough, rather than creeping on for ever
+		s.value = s.target
+	}
+	return true
+}
+
+// CCMap assigns midi control change numbers to the settings they control
+type CCMap map[int64]CCTarget
+
+// ParseCCMap reads assignments of controllers to target names, like 74=cutoff,71=resonance
+func ParseCCMap(s string) (CCMap, error) {
+	m := CCMap{}
+	if strings.TrimSpace(s) == "" {
+		return m, nil
+	}
+	for _, assignment := range strings.Split(s, ",") {
+		parts := strings.SplitN(assignment, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q is not an assignment like 74=cutoff", assignment)
+		}
+		cc, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+		if err != nil || cc < 0 || cc > 127 {
+			return nil, fmt.Errorf("%q is not a controller number, 0 to 127", parts[0])
+		}
+		target, err := ParseCCTarget(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+		m[cc] = target
+	}
+	return m, nil
+}
+
+// String writes the assignments the way ParseCCMap reads them, in controller order
+func (m CCMap) String() string {
+	ccs := make([]int64, 0, len(m))
+	for cc := range m {
+		ccs = append(ccs, cc)
+	}
+	sort.Slice(ccs, func(i, j int) bool { return ccs[i] < ccs[j] })
+	assignments := make([]string, len(ccs))
+	for i, cc := range ccs {
+		assignments[i] = fmt.Sprintf("%d=%s", cc, m[cc])
+	}
+	return strings.Join(assignments, ",")
+}
+
+// ParseCCTarget looks up a setting by the name ParseCCMap knows it by
+func ParseCCTarget(name string) (CCTarget, error) {
+	for t, n := range ccTargetNames {
+		if n == name {
+			return t, nil
+		}
+	}
+	return CCCutoff, fmt.Errorf("unknown cc target %q", name)
+}
@@ -0,0 +1,64 @@
+package synth
+
+import "math"
+
+const (
+	chorusDelay    = 20.0 // milliseconds each tap sits around
+	maxChorusDepth = 10.0 // milliseconds either way the taps can sweep
+	chorusTaps     = 2
+)
+
+// Chorus mixes in copies delayed by up to Depth milliseconds, swept at Rate Hz
+type Chorus struct {
+	Rate  float64
+	Depth float64
+	Mix   float64
+
+	sampleRate float64
+	lfos       [chorusTaps]LFO // one per tap, spread apart in phase
+	buf        []float64
+	idx        int
+}
+
+// NewChorus makes a chorus, its buffer sized for the longest delay at the context's sample rate
+func NewChorus(ac *AudioContext, rate, depth, mix float64) *Chorus {
+	c := &Chorus{
+		Rate:       rate,
+		Depth:      depth,
+		Mix:        mix,
+		sampleRate: float64(ac.SampleRate),
+		buf:        make([]float64, int((chorusDelay+maxChorusDepth)/1000*float64(ac.SampleRate))+2),
+	}
+	for i := range c.lfos {
+		c.lfos[i].phase = float64(i) / chorusTaps
+	}
+	return c
+}
+
+// Process adds the chorus to one sample
+func (c *Chorus) Process(sample float64) float64 {
+	c.buf[c.idx] = sample
+	depth := clamp(c.Depth, 0, maxChorusDepth)
+	wet := 0.0
+	for i := range c.lfos {
+		c.lfos[i].Rate = c.Rate
+		ms := chorusDelay + depth*(2*c.lfos[i].Advance(1/c.sampleRate)-1)
+		wet += readFractional(c.buf, c.idx, ms/1000*c.sampleRate)
+	}
+	wet /= chorusTaps
+	c.idx = (c.idx + 1) % len(c.buf)
+	return (sample + c.Mix*wet) / (1 + c.Mix)
+}
+
+// readFractional looks back delay samples from idx in a circular buffer, interpolating
+func readFractional(buf []float64, idx int, delay float64) float64 {
+	pos := float64(idx) - delay
+	if pos < 0 {
+		pos += float64(len(buf))
+	}
+	i := int(math.Floor(pos))
+	frac := pos - float64(i)
+	a := buf[i%len(buf)]
+	b := buf[(i+1)%len(buf)]
+	return a + (b-a)*frac
+}
@@ -0,0 +1,59 @@
+package synth
+
+import "math"
+
+const (
+	clickFreq   = 1000.0 // pitch of the click on each beat in Hz
+	clickAccent = 1500.0 // pitch of the click on the downbeat
+	clickDecay  = 0.008  // seconds the blip takes to die away to a third
+	clickLength = 0.05   // seconds after which what's left of the blip is cut off
+	clickLevel  = 0.4
+)
+
+// Click mixes a metronome into the output, keeping time with a Clock
+type Click struct {
+	sampleRate  float64
+	clock       *Clock
+	steps       clockSteps
+	beatsPerBar int     // 0 or 1 for no accent
+	sinceBeat   float64 // samples since the last beat began
+	beat        int     // beats into the bar
+}
+
+// NewClick makes a metronome beating quarter notes by the clock, in bars of beatsPerBar
+func NewClick(ac *AudioContext, clock *Clock, beatsPerBar int) *Click {
+	return &Click{
+		sampleRate:  float64(ac.SampleRate),
+		clock:       clock,
+		beatsPerBar: beatsPerBar,
+	}
+}
+
+// Process adds the click to one sample
+func (c *Click) Process(sample float64) float64 {
+	if beat, ok := c.steps.next(c.clock, 4); ok {
+		c.sinceBeat = 0
+		c.beat = 0
+		if c.beatsPerBar > 1 {
+			c.beat = int(beat % int64(c.beatsPerBar))
+		}
+	}
+	t := c.sinceBeat / c.sampleRate
+	c.sinceBeat++
+	if t >= clickLength {
+		return sample
+	}
+	freq := clickFreq
+	if c.beat == 0 && c.beatsPerBar > 1 {
+		freq = clickAccent
+	}
+	return sample + clickLevel*math.Exp(-t/clickDecay)*math.Sin(2*math.Pi*freq*t)
+}
+
+// MakeClickGen plays the metronome on its own, through the effects
+func MakeClickGen(ac *AudioContext, clock *Clock, beatsPerBar int, effects ...Effect) SoundGen {
+	params := DefaultParams()
+	params.Clock = clock
+	translator := MakeMidiTranslator(ac, params, func() []Event { return nil }, NewVoicePool(1, Envelope{}))
+	return MakeOscGen(ac, params, translator, Sine, append([]Effect{NewClick(ac, clock, beatsPerBar)}, effects...)...)
+}
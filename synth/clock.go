@@ -0,0 +1,107 @@
+package synth
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+const (
+	clockTicksPerQuarter = 24 // midi clock's resolution
+	clockTicksPerWhole   = 4 * clockTicksPerQuarter
+)
+
+// DefaultBPM is the tempo a clock keeps when nothing sets one
+const DefaultBPM = 120
+
+// Clock is the tempo shared by everything that plays in time, moved on by the first sound to each sample
+type Clock struct {
+	sampleRate float64
+	bpm        uint64  // quarter notes a minute as float64 bits, read from other goroutines
+	samples    int64   // samples it's been moved on by
+	position   float64 // whole notes since it started
+}
+
+// NewClock makes a clock at bpm quarter notes a minute for the context's sample rate
+func NewClock(ac *AudioContext, bpm float64) *Clock {
+	if bpm <= 0 {
+		bpm = DefaultBPM
+	}
+	return &Clock{sampleRate: float64(ac.SampleRate), bpm: math.Float64bits(bpm)}
+}
+
+// BPM is the clock's tempo in quarter notes a minute, safe to call while the generator runs
+func (c *Clock) BPM() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&c.bpm))
+}
+
+// SetBPM changes the tempo, safe to call while the generator runs
+func (c *Clock) SetBPM(bpm float64) {
+	atomic.StoreUint64(&c.bpm, math.Float64bits(bpm))
+}
+
+// reach moves the clock on to the sample, counted from when the clock began
+func (c *Clock) reach(sample int64) {
+	for ; c.samples < sample; c.samples++ {
+		c.position += c.BPM() / 240 / c.sampleRate
+	}
+}
+
+// restart goes back to the top of the bar, for START
+func (c *Clock) restart() {
+	c.position = 0
+}
+
+// clockSteps follows a clock through steps of a whole note divided by perWhole
+type clockSteps struct {
+	step  int64 // the step the clock was last in
+	begun bool
+}
+
+// next reports the step the clock is in and whether it's moved into it since last asked
+func (s *clockSteps) next(c *Clock, perWhole float64) (int64, bool) {
+	step := int64(math.Floor(c.position * perWhole))
+	if s.begun && step == s.step {
+		return step, false
+	}
+	s.step, s.begun = step, true
+	return step, true
+}
+
+// midiClock follows an incoming midi clock, counting its ticks and working out its tempo
+type midiClock struct {
+	running bool
+	ticks   int     // since the clock last started
+	times   []int64 // timestamps in milliseconds of up to a quarter note of the latest ticks
+}
+
+// tick records a TIMING CLOCK, returning the ticks since START and whether the clock is running
+func (c *midiClock) tick(timestamp int64) (int, bool) {
+	c.times = append(c.times, timestamp)
+	if len(c.times) > clockTicksPerQuarter+1 {
+		c.times = c.times[1:]
+	}
+	if !c.running {
+		return c.ticks, false
+	}
+	c.ticks++
+	return c.ticks - 1, true
+}
+
+// start begins counting again from the top, for START
+func (c *midiClock) start() {
+	c.running = true
+	c.ticks = 0
+}
+
+// bpm is the clock's tempo averaged over the last quarter note, 0 until there are ticks enough to tell
+func (c *midiClock) bpm() float64 {
+	if len(c.times) < 2 {
+		return 0
+	}
+	elapsed := c.times[len(c.times)-1] - c.times[0]
+	if elapsed <= 0 {
+		return 0
+	}
+	quarters := float64(len(c.times)-1) / clockTicksPerQuarter
+	return quarters * 60000 / float64(elapsed)
+}
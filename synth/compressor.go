@@ -0,0 +1,61 @@
+package synth
+
+import "math"
+
+// Compressor turns the master bus down by Ratio over Threshold dBFS, then up by Makeup dB
+type Compressor struct {
+	Threshold float64
+	Ratio     float64
+	Makeup    float64
+
+	attack   float64 // per sample envelope coefficients
+	release  float64
+	envelope float64 // following the signal's peaks
+}
+
+// NewCompressor makes a compressor for the context's sample rate, attack and release in seconds
+func NewCompressor(ac *AudioContext, threshold, ratio, attack, release, makeup float64) *Compressor {
+	deltaT := 1 / float64(ac.SampleRate)
+	return &Compressor{
+		Threshold: threshold,
+		Ratio:     ratio,
+		Makeup:    makeup,
+		attack:    1 - math.Exp(-deltaT/math.Max(attack, deltaT)),
+		release:   1 - math.Exp(-deltaT/math.Max(release, deltaT)),
+	}
+}
+
+// Process compresses one sample
+func (c *Compressor) Process(sample float64) float64 {
+	return sample * c.follow(math.Abs(sample))
+}
+
+// ProcessStereo compresses both channels together by the louder
+func (c *Compressor) ProcessStereo(left, right float64) (float64, float64) {
+	gain := c.follow(math.Max(math.Abs(left), math.Abs(right)))
+	return left * gain, right * gain
+}
+
+// Reduction is how far in dB the compressor is turning the signal down, before the makeup gain
+func (c *Compressor) Reduction() float64 {
+	over := levelToDB(c.envelope) - c.Threshold
+	if over <= 0 || c.Ratio <= 1 {
+		return 0
+	}
+	return over * (1 - 1/c.Ratio)
+}
+
+// follow moves the envelope on to the peak, returning the gain to apply
+func (c *Compressor) follow(peak float64) float64 {
+	if peak > c.envelope {
+		c.envelope += (peak - c.envelope) * c.attack
+	} else {
+		c.envelope += (peak - c.envelope) * c.release
+	}
+	return math.Pow(10, (c.Makeup-c.Reduction())/20)
+}
+
+// levelToDB is a level, 1 being full scale, in dBFS
+func levelToDB(level float64) float64 {
+	return 20 * math.Log10(level)
+}
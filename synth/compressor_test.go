@@ -0,0 +1,49 @@
+package synth
+
+import (
+	"math"
+	"testing"
+)
+
+// compressSquare returns the settled level of a square at level dBFS through the compressor
+func compressSquare(c *Compressor, level float64) float64 {
+	amplitude := math.Pow(10, level/20)
+	out := 0.0
+	for i := 0; i < testContext.SampleRate; i++ {
+		in := amplitude
+		if i/50%2 == 1 {
+			in = -amplitude
+		}
+		out = math.Abs(c.Process(in))
+	}
+	return levelToDB(out)
+}
+
+func TestCompressorRatio(t *testing.T) {
+	tests := []struct {
+		level, threshold, ratio, makeup float64
+		want                            float64
+	}{
+		{-6, -18, 4, 0, -15},   // 12dB over comes out 3dB over
+		{-6, -18, 2, 0, -12},   // and 6dB over at 2:1
+		{-10, -20, 10, 0, -19}, // and 1dB over at 10:1
+		{-6, -18, 4, 6, -9},    // made back up
+		{-24, -18, 4, 0, -24},  // under the threshold it's left alone
+		{-6, -18, 1, 0, -6},    // as it is at 1:1
+	}
+	for _, tt := range tests {
+		c := NewCompressor(testContext, tt.threshold, tt.ratio, 0.01, 0.1, tt.makeup)
+		if got := compressSquare(c, tt.level); math.Abs(got-tt.want) > 0.1 {
+			t.Errorf("%g dBFS through %g:1 at %g dBFS with %gdB makeup came out at %.2f dBFS, want %g",
+				tt.level, tt.ratio, tt.threshold, tt.makeup, got, tt.want)
+		}
+	}
+}
+
+func TestCompressorReduction(t *testing.T) {
+	c := NewCompressor(testContext, -18, 4, 0.01, 0.1, 0)
+	compressSquare(c, -6)
+	if r := c.Reduction(); math.Abs(r-9) > 0.1 {
+		t.Errorf("12dB over at 4:1 reports %.2fdB of reduction, want 9", r)
+	}
+}
@@ -0,0 +1,59 @@
+package synth
+
+import "sync"
+
+// ControlQueue carries setting changes from other goroutines to the translator
+type ControlQueue struct {
+	mu        sync.Mutex
+	pending   []controlChange
+	spare     []controlChange // the last batch applied, reused for the next
+	followers []*ControlQueue // the queues changes are passed on to instead, once split
+}
+
+type controlChange struct {
+	target CCTarget
+	value  float64
+}
+
+// Set queues a change of target to a value, 0 to 1, scaled across its range as a controller's would be, replacing any not yet applied
+func (q *ControlQueue) Set(target CCTarget, value float64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, f := range q.followers {
+		f.Set(target, value)
+	}
+	if len(q.followers) > 0 {
+		return
+	}
+	for i := range q.pending { // only the latest value counts, so a queue no one drains stays small
+		if q.pending[i].target == target {
+			q.pending[i].value = clamp(value, 0, 1)
+			return
+		}
+	}
+	q.pending = append(q.pending, controlChange{target: target, value: clamp(value, 0, 1)})
+}
+
+// Split makes n queues that each get every change set on q from then on
+func (q *ControlQueue) Split(n int) []*ControlQueue {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.followers = make([]*ControlQueue, n)
+	for i := range q.followers {
+		q.followers[i] = &ControlQueue{}
+	}
+	q.pending = q.pending[:0]
+	return q.followers
+}
+
+// drain hands each queued change to apply, in the order their targets were first set
+func (q *ControlQueue) drain(apply func(target CCTarget, value float64)) {
+	q.mu.Lock()
+	changes := q.pending
+	q.pending = q.spare[:0]
+	q.mu.Unlock()
+	for _, c := range changes {
+		apply(c.target, c.value)
+	}
+	q.spare = changes
+}
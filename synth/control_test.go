@@ -0,0 +1,20 @@
+package synth
+
+import "testing"
+
+func TestControlQueueKeepsTheLatest(t *testing.T) {
+	controls := &ControlQueue{}
+	queues := controls.Split(2)
+	for i := 0; i <= 1000; i++ {
+		controls.Set(CCCutoff, float64(i)/1000)
+		controls.Set(CCResonance, 0.5)
+	}
+	got := map[CCTarget]float64{}
+	queues[0].drain(func(target CCTarget, value float64) { got[target] = value })
+	if got[CCCutoff] != 1 || got[CCResonance] != 0.5 {
+		t.Errorf("applied %v, want the cutoff as it was last set", got)
+	}
+	if len(queues[1].pending) != 2 {
+		t.Errorf("queued %d changes undrained, want one for each target", len(queues[1].pending))
+	}
+}
@@ -0,0 +1,25 @@
+package synth
+
+import "math"
+
+// dcBlockerCutoff is low enough to leave the music alone while taking out any offset
+const dcBlockerCutoff = 20.0
+
+// DCBlocker is a one-pole high-pass taking out the DC offset waveshaping leaves
+type DCBlocker struct {
+	pole       float64
+	prevInput  float64
+	prevOutput float64
+}
+
+// NewDCBlocker makes a DC blocker for the context's sample rate, passing everything above about 20Hz
+func NewDCBlocker(ac *AudioContext) *DCBlocker {
+	return &DCBlocker{pole: math.Exp(-2 * math.Pi * dcBlockerCutoff / float64(ac.SampleRate))}
+}
+
+// Process filters one sample
+func (d *DCBlocker) Process(sample float64) float64 {
+	out := sample - d.prevInput + d.pole*d.prevOutput
+	d.prevInput, d.prevOutput = sample, out
+	return out
+}
@@ -0,0 +1,86 @@
+package synth
+
+import "math"
+
+const (
+	// maxFeedback keeps the echoes dying away rather than building up forever
+	maxFeedback = 0.95
+	// maxSyncedDelay is the longest, in seconds, a tempo synced delay's buffer holds
+	maxSyncedDelay = 4.0
+	// delayGlide is the time constant in seconds a synced delay glides to a new tempo over
+	delayGlide = 0.05
+)
+
+// Delay is an echo, a circular buffer fed back by Feedback and mixed in by Mix
+type Delay struct {
+	Feedback float64
+	Mix      float64
+
+	buf    []float64
+	idx    int
+	length float64 // samples the echoes are behind, up to the buffer's length
+
+	clock    *Clock  // the tempo a synced delay follows, nil for a fixed time
+	division float64 // whole notes between a synced delay's echoes
+	rate     float64 // samples a second
+	glide    float64 // fraction of the way to a new time a synced delay moves each sample
+}
+
+// NewDelay makes a delay of the given time in milliseconds, its buffer sized for the context's sample rate
+func NewDelay(ac *AudioContext, ms, feedback, mix float64) *Delay {
+	length := int(ms / 1000 * float64(ac.SampleRate))
+	if length < 1 {
+		length = 1
+	}
+	return &Delay{
+		Feedback: feedback,
+		Mix:      mix,
+		buf:      make([]float64, length),
+		length:   float64(length),
+	}
+}
+
+// NewSyncedDelay makes a delay whose echoes are division of a whole note apart at the clock's tempo
+func NewSyncedDelay(ac *AudioContext, clock *Clock, division, feedback, mix float64) *Delay {
+	d := &Delay{
+		Feedback: feedback,
+		Mix:      mix,
+		buf:      make([]float64, int(maxSyncedDelay*float64(ac.SampleRate))+1),
+		clock:    clock,
+		division: division,
+		rate:     float64(ac.SampleRate),
+		glide:    1 - math.Exp(-1/(delayGlide*float64(ac.SampleRate))),
+	}
+	d.length = d.syncedLength()
+	return d
+}
+
+// syncedLength is how many samples the clock's tempo puts between echoes
+func (d *Delay) syncedLength() float64 {
+	return clamp(d.division*240/d.clock.BPM()*d.rate, 1, float64(len(d.buf)-1))
+}
+
+// Process adds the echo to one sample
+func (d *Delay) Process(sample float64) float64 {
+	if d.clock != nil {
+		d.length += (d.syncedLength() - d.length) * d.glide
+	}
+	delayed := d.tap()
+	d.buf[d.idx] = sample + clamp(d.Feedback, 0, maxFeedback)*delayed
+	d.idx = (d.idx + 1) % len(d.buf)
+	return sample + d.Mix*delayed
+}
+
+// tap reads the buffer length samples back, between samples when that's fractional
+func (d *Delay) tap() float64 {
+	pos := float64(d.idx) - d.length
+	if pos < 0 {
+		pos += float64(len(d.buf))
+	}
+	i := int(pos)
+	frac := pos - float64(i)
+	if frac == 0 {
+		return d.buf[i%len(d.buf)]
+	}
+	return d.buf[i%len(d.buf)]*(1-frac) + d.buf[(i+1)%len(d.buf)]*frac
+}
@@ -0,0 +1,47 @@
+package synth
+
+import (
+	"math"
+	"math/rand"
+)
+
+// how far a voice wanders either way at an analog amount of 1
+const (
+	analogCents = 8.0
+	analogLevel = 0.06
+
+	driftInterval = 0.3 // seconds, on average, between the random walk picking somewhere new to head
+	driftTime     = 0.2 // seconds it takes to get most of the way there
+)
+
+// drift is a slow random walk between -1 and 1, wandering like an analog oscillator's tuning
+type drift struct {
+	value     float64
+	target    float64
+	untilNext float64 // seconds until the next target is picked
+	started   bool
+}
+
+// advance moves the walk on by deltaT, starting from a random point
+func (d *drift) advance(rng *rand.Rand, deltaT float64) float64 {
+	if !d.started {
+		d.value, d.started = rng.Float64()*2-1, true
+		d.target = d.value
+	}
+	if d.untilNext -= deltaT; d.untilNext <= 0 {
+		d.target = rng.Float64()*2 - 1
+		d.untilNext = driftInterval * (0.5 + rng.Float64())
+	}
+	d.value += (d.target - d.value) * (1 - math.Exp(-deltaT/driftTime))
+	return d.value
+}
+
+// analogPitch is the frequency multiplier the voice's pitch drift puts on it at an analog amount
+func (v *Voice) analogPitch(amount float64, rng *rand.Rand, deltaT float64) float64 {
+	return math.Pow(2, amount*analogCents*v.pitchDrift.advance(rng, deltaT)/1200)
+}
+
+// analogGain is the gain its level drift puts on it, 1 at an amount of 0
+func (v *Voice) analogGain(amount float64) float64 {
+	return 1 + amount*analogLevel*v.levelDrift.value
+}
@@ -0,0 +1,107 @@
+package synth
+
+import "math"
+
+type envStage int
+
+const (
+	envIdle envStage = iota
+	envAttack
+	envDecay
+	envSustain
+	envRelease
+)
+
+// Envelope is an ADSR amplitude envelope driven by the gate, times in seconds and Sustain from 0 to 1
+type Envelope struct {
+	Attack  float64
+	Decay   float64
+	Sustain float64
+	Release float64
+
+	stage envStage
+	level float64
+	quick bool // releasing over panicRelease rather than Release, until the next attack
+	// shorten is the fraction the attack, and with shortenDecay the decay, is cut short by
+	shorten      float64
+	shortenDecay bool
+}
+
+// panicRelease is how quickly a voice fades when all sound is cut, just long enough not to click
+const panicRelease = 0.005
+
+// minShortTime is as short as velocity makes an attack or decay, unless it was set shorter already
+const minShortTime = 0.002
+
+// Advance moves the envelope on by deltaT seconds and returns the gain, attacking from wherever it is
+func (e *Envelope) Advance(deltaT float64, gate bool) float64 {
+	if gate && (e.stage == envIdle || e.stage == envRelease) {
+		e.stage = envAttack
+		e.quick = false
+	} else if !gate && e.stage != envIdle && e.stage != envRelease {
+		e.stage = envRelease
+	}
+
+	switch e.stage {
+	case envAttack:
+		e.level += step(deltaT, e.shortened(e.Attack, true))
+		if e.level >= 1 {
+			e.level = 1
+			e.stage = envDecay
+		}
+	case envDecay:
+		e.level -= step(deltaT, e.shortened(e.Decay, e.shortenDecay)) * (1 - e.Sustain)
+		if e.level <= e.Sustain {
+			e.level = e.Sustain
+			e.stage = envSustain
+		}
+	case envSustain:
+		e.level = e.Sustain
+	case envRelease:
+		release := e.Release
+		if e.quick {
+			release = panicRelease
+		}
+		e.level -= step(deltaT, release)
+		if e.level <= 0 {
+			e.level = 0
+			e.stage = envIdle
+		}
+	}
+	return e.level
+}
+
+// cut sends the envelope into a release just quick enough not to click
+func (e *Envelope) cut() {
+	if e.stage != envIdle {
+		e.stage = envRelease
+		e.quick = true
+	}
+}
+
+// retrigger starts the attack again from the current level
+func (e *Envelope) retrigger() {
+	e.stage = envAttack
+	e.quick = false
+}
+
+// shortened is a stage's time, cut short by velocity when that applies to the stage
+func (e *Envelope) shortened(time float64, applies bool) float64 {
+	if !applies || e.shorten == 0 || time <= minShortTime {
+		return time
+	}
+	return math.Max(minShortTime, time*(1-e.shorten))
+}
+
+// Idle reports whether the envelope has finished its release and is silent
+func (e *Envelope) Idle() bool {
+	return e.stage == envIdle
+}
+
+// step is how far a full-scale ramp lasting time moves in deltaT, jumping for a time of 0 or less
+func step(deltaT, time float64) float64 {
+	if time <= 0 {
+		return 1
+	}
+	return deltaT / time
+}
@@ -0,0 +1,45 @@
+package synth
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// Fader ramps the output down to silence on request, last in the effects, so stopping doesn't pop
+type Fader struct {
+	sampleRate float64
+	fadeTime   uint64 // seconds as float64 bits, set from another goroutine, 0 until asked to fade
+	gain       float64
+	done       chan struct{}
+	once       sync.Once
+}
+
+// NewFader makes a fader for audio in the context's sample rate, starting at full volume
+func NewFader(ac *AudioContext) *Fader {
+	return &Fader{
+		sampleRate: float64(ac.SampleRate),
+		gain:       1,
+		done:       make(chan struct{}),
+	}
+}
+
+// FadeOut starts fading to silence over seconds, the channel closing once it gets there
+func (f *Fader) FadeOut(seconds float64) <-chan struct{} {
+	atomic.StoreUint64(&f.fadeTime, math.Float64bits(math.Max(seconds, 1e-6)))
+	return f.done
+}
+
+// Process applies the fade to one sample
+func (f *Fader) Process(sample float64) float64 {
+	bits := atomic.LoadUint64(&f.fadeTime)
+	if bits == 0 {
+		return sample
+	}
+	f.gain -= 1 / (math.Float64frombits(bits) * f.sampleRate)
+	if f.gain <= 0 {
+		f.gain = 0
+		f.once.Do(func() { close(f.done) })
+	}
+	return sample * f.gain
+}
@@ -0,0 +1,103 @@
+package synth
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	maxResonance = 0.98   // keeps the filter just short of self-oscillation
+	keyTrackFreq = 261.63 // middle C, where key tracking leaves the cutoff as it's set
+)
+
+// FilterRouting is how each voice's filters are arranged
+type FilterRouting int
+
+const (
+	FilterSingle   FilterRouting = iota // the low-pass alone
+	FilterSeries                        // the low-pass into the high-pass, a band-pass between their cutoffs
+	FilterParallel                      // the low-pass and the high-pass side by side and summed, a notch between them
+)
+
+var filterRoutingNames = map[FilterRouting]string{
+	FilterSingle:   "single",
+	FilterSeries:   "series",
+	FilterParallel: "parallel",
+}
+
+func (r FilterRouting) String() string {
+	return filterRoutingNames[r]
+}
+
+// ParseFilterRouting looks up a filter routing by its name: single, series or parallel
+func ParseFilterRouting(name string) (FilterRouting, error) {
+	for r, n := range filterRoutingNames {
+		if n == name {
+			return r, nil
+		}
+	}
+	return FilterSingle, fmt.Errorf("unknown filter routing %q", name)
+}
+
+// Filter is a resonant state-variable filter, Cutoff in Hz and Resonance from 0 towards 1
+type Filter struct {
+	Cutoff    float64
+	Resonance float64
+	HighPass  bool
+
+	sampleRate float64
+	ic1eq      float64 // integrator states carried between samples
+	ic2eq      float64
+
+	// coefficients, recomputed only when the cutoff or resonance move
+	lastCutoff    float64
+	lastResonance float64
+	a1, a2, a3    float64
+	k             float64 // damping
+}
+
+// NewFilter makes a low-pass filter for audio in the context's sample rate
+func NewFilter(ac *AudioContext, cutoff, resonance float64) *Filter {
+	return &Filter{
+		Cutoff:     cutoff,
+		Resonance:  resonance,
+		sampleRate: float64(ac.SampleRate),
+		lastCutoff: -1,
+	}
+}
+
+// Process runs one sample through the filter and returns the low or high-passed result
+func (f *Filter) Process(sample float64) float64 {
+	if f.Cutoff != f.lastCutoff || f.Resonance != f.lastResonance {
+		f.updateCoefficients()
+	}
+	v3 := sample - f.ic2eq
+	v1 := f.a1*f.ic1eq + f.a2*v3
+	v2 := f.ic2eq + f.a2*f.ic1eq + f.a3*v3
+	f.ic1eq = 2*v1 - f.ic1eq
+	f.ic2eq = 2*v2 - f.ic2eq
+	if math.IsNaN(v2) || math.IsInf(v2, 0) { // never let a bad sample poison the state
+		f.ic1eq, f.ic2eq = 0, 0
+		return 0
+	}
+	if f.HighPass {
+		return sample - f.k*v1 - v2
+	}
+	return v2
+}
+
+func (f *Filter) updateCoefficients() {
+	cutoff := clamp(f.Cutoff, 10, 0.49*f.sampleRate) // tan blows up at nyquist
+	resonance := clamp(f.Resonance, 0, maxResonance)
+	g := math.Tan(math.Pi * cutoff / f.sampleRate)
+	f.k = 2 - 2*resonance
+	f.a1 = 1 / (1 + g*(g+f.k))
+	f.a2 = g * f.a1
+	f.a3 = g * f.a2
+	f.lastCutoff = f.Cutoff
+	f.lastResonance = f.Resonance
+}
+
+func clamp(x, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, x))
+}
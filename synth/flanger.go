@@ -0,0 +1,42 @@
+package synth
+
+const (
+	flangerMinDelay    = 0.5 // milliseconds at the shallow end of the sweep
+	flangerMaxDelay    = 5.0 // milliseconds at full depth
+	maxFlangerFeedback = 0.95
+)
+
+// Flanger is a very short delay fed back into itself, swept by an LFO
+type Flanger struct {
+	Rate     float64
+	Depth    float64
+	Feedback float64
+	Mix      float64
+
+	sampleRate float64
+	lfo        LFO
+	buf        []float64
+	idx        int
+}
+
+// NewFlanger makes a flanger, its buffer sized for the longest delay at the context's sample rate
+func NewFlanger(ac *AudioContext, rate, depth, feedback, mix float64) *Flanger {
+	return &Flanger{
+		Rate:       rate,
+		Depth:      depth,
+		Feedback:   feedback,
+		Mix:        mix,
+		sampleRate: float64(ac.SampleRate),
+		buf:        make([]float64, int(flangerMaxDelay/1000*float64(ac.SampleRate))+2),
+	}
+}
+
+// Process adds the flanging to one sample
+func (f *Flanger) Process(sample float64) float64 {
+	f.lfo.Rate = f.Rate
+	ms := flangerMinDelay + clamp(f.Depth, 0, 1)*(flangerMaxDelay-flangerMinDelay)*f.lfo.Advance(1/f.sampleRate)
+	delayed := readFractional(f.buf, f.idx, ms/1000*f.sampleRate)
+	f.buf[f.idx] = sample + clamp(f.Feedback, -maxFlangerFeedback, maxFlangerFeedback)*delayed
+	f.idx = (f.idx + 1) % len(f.buf)
+	return (sample + f.Mix*delayed) / (1 + f.Mix)
+}
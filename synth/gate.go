@@ -0,0 +1,58 @@
+package synth
+
+import "math"
+
+const (
+	gateHold    = 0.05  // seconds the level has to stay under the threshold before the gate closes
+	gateAttack  = 0.001 // seconds the gate takes to open
+	gateRelease = 0.05  // seconds it takes to close
+	gateFollow  = 0.005 // seconds the level follower takes to settle, smoothing it over a cycle or two
+)
+
+// Gate fades the output out while it stays under Threshold dBFS, -Inf leaving it open
+type Gate struct {
+	Threshold float64
+
+	follow  float64 // per sample coefficients
+	attack  float64
+	release float64
+	hold    int // samples under the threshold before closing
+
+	level float64 // following the signal's peaks
+	under int     // samples the level has been under for
+	gain  float64
+}
+
+// NewGate makes a gate at the threshold in dBFS for the context's sample rate
+func NewGate(ac *AudioContext, threshold float64) *Gate {
+	deltaT := 1 / float64(ac.SampleRate)
+	return &Gate{
+		Threshold: threshold,
+		follow:    1 - math.Exp(-deltaT/gateFollow),
+		attack:    1 - math.Exp(-deltaT/gateAttack),
+		release:   1 - math.Exp(-deltaT/gateRelease),
+		hold:      int(gateHold * float64(ac.SampleRate)),
+		gain:      1,
+	}
+}
+
+// ProcessStereo gates a pair of samples together, by the louder of them
+func (g *Gate) ProcessStereo(left, right float64) (float64, float64) {
+	peak := math.Max(math.Abs(left), math.Abs(right))
+	if peak > g.level {
+		g.level = peak // open straight away on a new note, the attack smooths it
+	} else {
+		g.level += (peak - g.level) * g.follow
+	}
+	if g.level >= math.Pow(10, g.Threshold/20) {
+		g.under = 0
+	} else if g.under < g.hold {
+		g.under++
+	}
+	if g.under < g.hold {
+		g.gain += (1 - g.gain) * g.attack
+	} else {
+		g.gain -= g.gain * g.release
+	}
+	return left * g.gain, right * g.gain
+}
@@ -0,0 +1,46 @@
+package synth
+
+import (
+	"fmt"
+	"math"
+)
+
+// GlideMode decides which notes glide from the one before
+type GlideMode int
+
+const (
+	GlideAlways GlideMode = iota // every note glides from the last
+	GlideLegato                  // only notes played while another key is still down
+)
+
+var glideModeNames = map[GlideMode]string{
+	GlideAlways: "always",
+	GlideLegato: "legato",
+}
+
+func (m GlideMode) String() string {
+	return glideModeNames[m]
+}
+
+// ParseGlideMode looks up a glide mode by its name: always or legato
+func ParseGlideMode(name string) (GlideMode, error) {
+	for m, n := range glideModeNames {
+		if n == name {
+			return m, nil
+		}
+	}
+	return GlideAlways, fmt.Errorf("unknown glide mode %q", name)
+}
+
+// glideFreq moves the voice's frequency on by deltaT through an even glide in pitch to target
+func (v *Voice) glideFreq(params *Params, target, deltaT float64) float64 {
+	if params.Glide <= 0 || v.glideFrom <= 0 || target <= 0 || (params.GlideMode == GlideLegato && !v.legato) {
+		return target
+	}
+	progress := v.glideElapsed / (params.Glide / 1000)
+	if progress >= 1 {
+		return target
+	}
+	v.glideElapsed += deltaT
+	return v.glideFrom * math.Pow(target/v.glideFrom, progress)
+}
@@ -0,0 +1,19 @@
+package synth
+
+import "math"
+
+// LFO is a low frequency sine for modulation, Rate in Hz and Depth from 0 to 1
+type LFO struct {
+	Rate  float64
+	Depth float64
+
+	phase float64 // in cycles, carried between samples and buffers
+}
+
+// Advance moves the LFO on by deltaT seconds and returns its value between 0 and 1
+func (l *LFO) Advance(deltaT float64) float64 {
+	value := 0.5 + 0.5*sine(l.phase)
+	l.phase += l.Rate * deltaT
+	l.phase -= math.Floor(l.phase)
+	return value
+}
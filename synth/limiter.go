@@ -0,0 +1,53 @@
+package synth
+
+import "math"
+
+const (
+	limiterAttack  = 0.001 // seconds the gain takes to come down on a peak
+	limiterRelease = 0.1   // seconds it takes to recover afterwards
+)
+
+// Limiter keeps the master bus under Threshold dBFS, clipping whatever gets past the attack
+type Limiter struct {
+	Threshold float64
+
+	attack   float64 // per sample envelope coefficients
+	release  float64
+	envelope float64 // following the signal's peaks
+}
+
+// NewLimiter makes a limiter at the threshold in dBFS for the context's sample rate
+func NewLimiter(ac *AudioContext, threshold float64) *Limiter {
+	deltaT := 1 / float64(ac.SampleRate)
+	return &Limiter{
+		Threshold: threshold,
+		attack:    1 - math.Exp(-deltaT/limiterAttack),
+		release:   1 - math.Exp(-deltaT/limiterRelease),
+	}
+}
+
+// Process limits one sample
+func (l *Limiter) Process(sample float64) float64 {
+	gain, threshold := l.follow(math.Abs(sample))
+	return clamp(sample*gain, -threshold, threshold)
+}
+
+// ProcessStereo limits both channels together by the louder
+func (l *Limiter) ProcessStereo(left, right float64) (float64, float64) {
+	gain, threshold := l.follow(math.Max(math.Abs(left), math.Abs(right)))
+	return clamp(left*gain, -threshold, threshold), clamp(right*gain, -threshold, threshold)
+}
+
+// follow moves the envelope on to the peak, returning the gain to apply and the threshold as a level
+func (l *Limiter) follow(peak float64) (float64, float64) {
+	threshold := math.Pow(10, math.Min(l.Threshold, 0)/20)
+	if peak > l.envelope {
+		l.envelope += (peak - l.envelope) * l.attack
+	} else {
+		l.envelope += (peak - l.envelope) * l.release
+	}
+	if l.envelope > threshold {
+		return threshold / l.envelope, threshold
+	}
+	return 1, threshold
+}
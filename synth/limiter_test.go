@@ -0,0 +1,45 @@
+package synth
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLimiterCeiling(t *testing.T) {
+	for _, threshold := range []float64{0, -1, -6} {
+		for _, amplitude := range []float64{1.5, 4, 10} {
+			l := NewLimiter(testContext, threshold)
+			ceiling := math.Pow(10, threshold/20)
+			peak := 0.0
+			for i := 0; i < testContext.SampleRate; i++ {
+				in := amplitude * math.Sin(2*math.Pi*440*float64(i)/float64(testContext.SampleRate))
+				peak = math.Max(peak, math.Abs(l.Process(in)))
+			}
+			if peak > ceiling {
+				t.Errorf("%g dBFS limiter let a peak of %g through at %g, over %g", threshold, peak, amplitude, ceiling)
+			}
+		}
+	}
+}
+
+func TestLimiterStereoCeiling(t *testing.T) {
+	l := NewLimiter(testContext, -1)
+	ceiling := math.Pow(10, -1.0/20)
+	for i := 0; i < testContext.SampleRate; i++ {
+		s := 3 * math.Sin(2*math.Pi*220*float64(i)/float64(testContext.SampleRate))
+		left, right := l.ProcessStereo(s, -s/2)
+		if math.Abs(left) > ceiling || math.Abs(right) > ceiling {
+			t.Fatalf("sample %d came out at %g, %g, over %g", i, left, right, ceiling)
+		}
+	}
+}
+
+func TestLimiterTransparent(t *testing.T) {
+	l := NewLimiter(testContext, 0)
+	for i := 0; i < testContext.SampleRate; i++ {
+		in := 0.5 * math.Sin(2*math.Pi*440*float64(i)/float64(testContext.SampleRate))
+		if out := l.Process(in); out != in {
+			t.Fatalf("sample %d under the threshold changed from %g to %g", i, in, out)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package synth
+
+import (
+	"math"
+	"sync"
+)
+
+// meterWindow is the stretch of audio, in seconds, each reading of a Meter covers
+const meterWindow = 0.05
+
+// Meter measures the level of PCM written to it, readable from any goroutine
+type Meter struct {
+	mu         sync.Mutex
+	ac         AudioContext
+	window     int // samples, across every channel, in each reading
+	peak       float64
+	sumSquares float64
+	count      int
+	lastPeak   float64
+	lastRMS    float64
+}
+
+// NewMeter makes a meter for audio in the context's format
+func NewMeter(ac *AudioContext) *Meter {
+	return NewMeterWindow(ac, meterWindow)
+}
+
+// NewMeterWindow makes a meter whose readings each cover the given seconds of audio
+func NewMeterWindow(ac *AudioContext, seconds float64) *Meter {
+	window := int(seconds*float64(ac.SampleRate)) * ac.NumChannels
+	if window < 1 {
+		window = 1
+	}
+	return &Meter{ac: *ac, window: window}
+}
+
+// Write measures PCM frames, every channel counting alike
+func (m *Meter) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := 0; i+m.ac.BitDepthInBytes <= len(p); i += m.ac.BitDepthInBytes {
+		sample := m.ac.sampleAt(p[i:])
+		m.peak = math.Max(m.peak, math.Abs(sample))
+		m.sumSquares += sample * sample
+		m.count++
+		if m.count >= m.window {
+			m.lastPeak, m.lastRMS = m.peak, math.Sqrt(m.sumSquares/float64(m.count))
+			m.peak, m.sumSquares, m.count = 0, 0, 0
+		}
+	}
+	return len(p), nil
+}
+
+// Level is the peak and RMS level, 0 to 1, of the latest window of audio
+func (m *Meter) Level() (peak, rms float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastPeak, m.lastRMS
+}
@@ -0,0 +1,34 @@
+package synth
+
+// MakeMixer sums several generators, a poll's worth of frames at a time
+func MakeMixer(ac *AudioContext, gens ...SoundGen) SoundGen {
+	bytesPerFrame := ac.NumChannels * ac.BitDepthInBytes
+	chunk := make([]byte, pollFrames(ac)*bytesPerFrame)
+	mix := []float64{}
+	return func(buf []byte) (int, error) {
+		n := len(buf) / bytesPerFrame * bytesPerFrame
+		for start := 0; start < n; start += len(chunk) {
+			end := start + len(chunk)
+			if end > n {
+				end = n
+			}
+			mix = mix[:0]
+			for i := start; i < end; i += ac.BitDepthInBytes {
+				mix = append(mix, 0)
+			}
+			for _, gen := range gens {
+				read, err := gen(chunk[:end-start])
+				if err != nil {
+					return start, err
+				}
+				for i := 0; i+ac.BitDepthInBytes <= read; i += ac.BitDepthInBytes {
+					mix[i/ac.BitDepthInBytes] += ac.sampleAt(chunk[i:])
+				}
+			}
+			for i, sample := range mix {
+				ac.putSample(buf[start+i*ac.BitDepthInBytes:], sample)
+			}
+		}
+		return n, nil
+	}
+}
@@ -0,0 +1,22 @@
+package synth
+
+import "math/rand"
+
+// noise generates white or pink noise for a voice, in place of a pitched oscillator
+type noise struct {
+	rng        *rand.Rand
+	b0, b1, b2 float64 // pinking filter state
+}
+
+func (n *noise) white() float64 {
+	return n.rng.Float64()*2 - 1
+}
+
+// pink filters white noise down by 3dB an octave, using Paul Kellet's economy pinking filter
+func (n *noise) pink() float64 {
+	white := n.white()
+	n.b0 = 0.99765*n.b0 + white*0.0990460
+	n.b1 = 0.96300*n.b1 + white*0.2965164
+	n.b2 = 0.57000*n.b2 + white*1.0526913
+	return (n.b0 + n.b1 + n.b2 + white*0.1848) * 0.25
+}
@@ -0,0 +1,27 @@
+package synth
+
+import "math"
+
+// NOTE_MAP is the frequency in Hertz of every midi note, from 0 to 127, the tuning a pool plays in by default
+var NOTE_MAP = BuildNoteMap(440)
+
+// BuildNoteMap tunes the midi notes in equal temperament, with A4 (note 69) at a4 Hertz
+func BuildNoteMap(a4 float64) []float64 {
+	notes := make([]float64, 128)
+	for n := range notes {
+		notes[n] = a4 * math.Pow(2, float64(n-69)/12)
+	}
+	return notes
+}
+
+// noteFreq looks the note up in the pool's tuning, reporting false for a note the map doesn't cover
+func (vp *VoicePool) noteFreq(note int64) (float64, bool) {
+	notes := vp.notes
+	if notes == nil {
+		notes = NOTE_MAP
+	}
+	if note < 0 || note >= int64(len(notes)) {
+		return 0, false
+	}
+	return notes[note], true
+}
@@ -0,0 +1,97 @@
+package synth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// OneShot is a recorded sound a note plays through once, at the context's sample rate
+type OneShot []float64
+
+// SampleMap assigns midi notes to the one-shots they play in place of the oscillators, like drum pads
+type SampleMap map[int64]OneShot
+
+// ReadSampleMap reads assignments of notes to WAV files, like 36=kick.wav,38=snare.wav
+func ReadSampleMap(s string, sampleRate int) (SampleMap, error) {
+	m := SampleMap{}
+	if strings.TrimSpace(s) == "" {
+		return m, nil
+	}
+	for _, assignment := range strings.Split(s, ",") {
+		parts := strings.SplitN(assignment, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q is not an assignment like 36=kick.wav", assignment)
+		}
+		note, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+		if err != nil || note < 0 || note > 127 {
+			return nil, fmt.Errorf("%q is not a midi note, 0 to 127", parts[0])
+		}
+		path := strings.TrimSpace(parts[1])
+		sound, err := ReadOneShot(path, sampleRate)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %s", path, err.Error())
+		}
+		m[note] = sound
+	}
+	return m, nil
+}
+
+// ReadOneShot loads a WAV file's first channel, resampled to sampleRate
+func ReadOneShot(path string, sampleRate int) (OneShot, error) {
+	if sampleRate < 1 {
+		return nil, fmt.Errorf("can't resample to %dHz", sampleRate)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, errors.New("not a WAV file")
+	}
+	samples, rate, err := parseWavSamples(data[12:])
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 || rate == 0 {
+		return nil, errors.New("the sample is empty")
+	}
+	if rate == sampleRate {
+		return OneShot(samples), nil
+	}
+	step := float64(rate) / float64(sampleRate) // source samples each output sample moves on by
+	sound := make(OneShot, int(float64(len(samples))/step))
+	for i := range sound {
+		pos := float64(i) * step
+		j := int(pos)
+		next := samples[len(samples)-1]
+		if j+1 < len(samples) {
+			next = samples[j+1]
+		}
+		sound[i] = samples[j] + (next-samples[j])*(pos-float64(j))
+	}
+	return sound, nil
+}
+
+// Trigger plays a one-shot for the note to its end, outside the voices
+func (vp *VoicePool) Trigger(note int64, velocity float64, sound OneShot) {
+	for _, v := range vp.oneShots {
+		if v.Note == note {
+			v.Velocity, v.oneShot, v.oneShotPos = velocity, sound, 0
+			return
+		}
+	}
+	vp.oneShots = append(vp.oneShots, &Voice{Note: note, Velocity: velocity, oneShot: sound})
+}
+
+// nextOneShot is the voice's next sample of its one-shot, silence once it's finished
+func (v *Voice) nextOneShot() float64 {
+	if v.oneShotPos >= len(v.oneShot) {
+		return 0
+	}
+	sample := v.oneShot[v.oneShotPos]
+	v.oneShotPos++
+	return sample
+}
@@ -0,0 +1,118 @@
+package synth
+
+import (
+	"fmt"
+	"math"
+)
+
+// Waveform selects the shape of the oscillator
+type Waveform int
+
+const (
+	Sine Waveform = iota
+	Square
+	Sawtooth
+	Triangle
+	WhiteNoise
+	PinkNoise
+)
+
+var waveformNames = map[Waveform]string{
+	Sine:       "sine",
+	Square:     "square",
+	Sawtooth:   "saw",
+	Triangle:   "triangle",
+	WhiteNoise: "white",
+	PinkNoise:  "pink",
+}
+
+func (w Waveform) String() string {
+	return waveformNames[w]
+}
+
+// ParseWaveform looks up a waveform by its name: sine, square, saw, triangle, white or pink
+func ParseWaveform(name string) (Waveform, error) {
+	for w, n := range waveformNames {
+		if n == name {
+			return w, nil
+		}
+	}
+	return Sine, fmt.Errorf("unknown waveform %q", name)
+}
+
+// phase tracks an oscillator's place in its cycle as time since the note began
+type phase struct {
+	pos      float64
+	lastFreq float64
+}
+
+// next returns the phase in cycles and moves it on by deltaT at freq
+func (p *phase) next(freq, deltaT float64) float64 {
+	if freq != p.lastFreq { // resolve clicking on new notes and between frequency changes
+		p.pos = (p.lastFreq * p.pos) / freq
+	}
+	p.lastFreq = freq
+	cycles := freq * p.pos
+	p.pos += deltaT
+	return cycles
+}
+
+// isNoise reports whether the waveform is unpitched noise rather than a shape played at a frequency
+func (w Waveform) isNoise() bool {
+	return w == WhiteNoise || w == PinkNoise
+}
+
+// oscillate returns the waveform at phase in cycles, band-limited by PolyBLEP for a step above 0
+func (w Waveform) oscillate(phase, width, step float64) float64 {
+	frac := phase - math.Floor(phase)
+	switch w {
+	case Square:
+		out := -1.0
+		if frac < width {
+			out = 1
+		}
+		if step > 0 { // up at the start of the cycle, down at width
+			out += polyBLEP(frac, step) - polyBLEP(wrap(frac-width), step)
+		}
+		return out
+	case Sawtooth:
+		out := 2*frac - 2
+		if frac < 0.5 {
+			out = 2 * frac
+		}
+		if step > 0 { // down halfway through
+			out -= polyBLEP(wrap(frac-0.5), step)
+		}
+		return out
+	case Triangle:
+		switch {
+		case frac < 0.25:
+			return 4 * frac
+		case frac < 0.75:
+			return 2 - 4*frac
+		default:
+			return 4*frac - 4
+		}
+	default:
+		return sine(phase)
+	}
+}
+
+// polyBLEP smooths a jump of 2 at the start of a cycle, frac of the way through it
+func polyBLEP(frac, step float64) float64 {
+	step = math.Min(step, 0.5) // the corrections either side would overlap
+	switch {
+	case frac < step: // just after the jump
+		t := frac / step
+		return 2*t - t*t - 1
+	case frac > 1-step: // just before it
+		t := (frac - 1) / step
+		return t*t + 2*t + 1
+	}
+	return 0
+}
+
+// wrap brings a phase into [0, 1)
+func wrap(frac float64) float64 {
+	return frac - math.Floor(frac)
+}
@@ -0,0 +1,55 @@
+package synth
+
+import (
+	"math"
+	"testing"
+)
+
+// level is the amplitude of a second's samples at a whole freq
+func level(samples []float64, freq, sampleRate float64) float64 {
+	re, im := 0.0, 0.0
+	for i, s := range samples {
+		angle := 2 * math.Pi * freq * float64(i) / sampleRate
+		re += s * math.Cos(angle)
+		im -= s * math.Sin(angle)
+	}
+	return 2 * math.Hypot(re, im) / float64(len(samples))
+}
+
+// aliasing is the loudest alias of a harmonic above nyquist, in dB against the fundamental
+func aliasing(osc func(phase, freq float64) float64, freq, sampleRate float64) float64 {
+	samples := make([]float64, int(sampleRate))
+	for i := range samples {
+		samples[i] = osc(freq*float64(i)/sampleRate, freq)
+	}
+	worst := 0.0
+	for n := 2.0; n*freq < sampleRate; n++ {
+		if alias := sampleRate - n*freq; alias < sampleRate/4 {
+			worst = math.Max(worst, level(samples, alias, sampleRate))
+		}
+	}
+	return levelToDB(worst / level(samples, freq, sampleRate))
+}
+
+func TestBandLimitedAliasing(t *testing.T) {
+	const sampleRate, freq = 48000, 2900 // its harmonics fold back between the ones below nyquist
+	for _, wave := range []Waveform{Sawtooth, Square} {
+		naive := aliasing(func(phase, freq float64) float64 { return wave.oscillate(phase, 0.5, 0) }, freq, sampleRate)
+		limited := aliasing(func(phase, freq float64) float64 { return wave.oscillate(phase, 0.5, freq/sampleRate) }, freq, sampleRate)
+		if limited > naive-20 || limited > -40 {
+			t.Errorf("%s aliases at %.1fdB band-limited, against %.1fdB drawn as it is", wave, limited, naive)
+		}
+	}
+}
+
+func TestAdditiveAliasing(t *testing.T) {
+	const sampleRate, freq = 48000, 2900
+	h := Harmonics{}
+	for i := 0; i < 16; i++ {
+		h = append(h, 1/float64(i+1))
+	}
+	osc := func(phase, freq float64) float64 { return h.oscillate(phase, freq, sampleRate/2) }
+	if alias := aliasing(osc, freq, sampleRate); alias > -80 {
+		t.Errorf("the additive oscillator aliases at %.1fdB, it shouldn't play anything over nyquist", alias)
+	}
+}
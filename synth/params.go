@@ -0,0 +1,104 @@
+package synth
+
+import "math"
+
+// Params are the settings that can change while the synth plays, set by the translator
+type Params struct {
+	Channel   int64     // midi channel to listen to, 1 to 16, or 0 for all of them
+	Transpose int64     // semitones to shift every incoming note by
+	Snap      SnapScale // scale every incoming note is snapped into once transposed
+
+	Volume      float64 // master gain, 0.0 to 1.0, applied to the final mix
+	Pan         float64 // stereo position, -1 for hard left to 1 for hard right
+	Width       float64 // stereo width, 0 for mono, 1 as it is and above 1 wider
+	Haas        float64 // seconds to delay the right channel by for width, 0 for none
+	BendRange   float64 // pitch bend range in semitones, up and down
+	VibratoRate float64 // speed of the mod wheel vibrato in Hz
+
+	CCMap         CCMap         // controllers assigned to settings, on top of the fixed mod wheel, volume, pan and sustain
+	CCSlew        float64       // time constant in seconds that controllers glide to new values over, 0 to jump
+	Controls      *ControlQueue // changes to settings from other goroutines, or nil
+	VelocityCurve VelocityCurve // how key velocity maps onto loudness
+	Glide         float64       // milliseconds each note takes to glide from the last, 0 to jump straight there
+	GlideMode     GlideMode     // which notes glide
+	Detune        float64       // cents between each voice's two oscillators, 0 for a single oscillator
+	UnisonSpread  float64       // cents between the highest and lowest of each voice's unison oscillators
+	Sub           float64       // level of the sub oscillator an octave below, 0.0 (off) to 1.0
+	SubWave       Waveform      // the sub oscillator's waveform, sine or square
+	RingMod       float64       // frequency of the ring modulator in Hz, 0 for none
+	RingRatio     float64       // when above 0 the ring modulator follows each note at this multiple of its frequency instead
+	FM            bool          // modulate each oscillator's phase with a sine, 2 operator FM
+	FMRatio       float64       // the FM modulator's frequency as a multiple of the note's
+	FMIndex       float64       // how far the FM modulator swings the phase, in radians
+	Wavetable     Wavetable     // single cycle the oscillators play in place of the waveform, when loaded
+	Harmonics     Harmonics     // harmonic amplitudes the oscillators sum in place of the waveform, when given
+	BandLimit     bool          // smooth the saw and square's jumps so high notes don't alias
+	Analog        float64       // how far each voice's pitch and level wander, 0 for none to 1
+	SyncRatio     float64       // hard sync the oscillators to a silent master at the note, playing at this multiple of it, 0 for none
+	Samples       SampleMap     // notes that play one-shots in place of the oscillators
+
+	Cutoff          float64          // low-pass filter cutoff in Hz
+	Resonance       float64          // low-pass filter resonance, 0.0 to 1.0
+	FilterRouting   FilterRouting    // whether a high-pass joins the low-pass, after it or alongside it
+	HPCutoff        float64          // high-pass filter cutoff in Hz
+	HPResonance     float64          // high-pass filter resonance, 0.0 to 1.0
+	VelocityCutoff  float64          // octaves a note struck at full velocity opens its filter by
+	KeyTrack        float64          // how closely each note's cutoff follows its pitch from keyTrackFreq, 0 fixed to 1 one-for-one
+	FilterEnvAmount float64          // octaves the filter envelope sweeps the cutoff by at its peak, negative sweeping it down
+	Aftertouch      AftertouchTarget // what pressing harder on held keys changes
+	Drive           float64          // gain into the soft clipper, 0 for a clean signal
+	DCBlock         bool             // high-pass the final mix at about 20Hz to remove any DC offset
+
+	CompRatio     float64 // compress the master bus over CompThreshold by this ratio, after the volume, 1 for none
+	CompThreshold float64 // level in dBFS the compressor starts turning the output down over
+	CompAttack    float64 // seconds the compressor takes to come down on a peak
+	CompRelease   float64 // seconds it takes to recover afterwards
+	CompMakeup    float64 // dB the compressed output is turned back up by
+
+	Limiter          bool    // limit the master bus, after the volume, so peaks never go over
+	LimiterThreshold float64 // level the limiter holds the output under, in dBFS
+	GateThreshold    float64 // level in dBFS the output is muted under, -Inf to never mute it
+
+	PulseWidth float64 // fraction of each cycle the square wave spends high, 0.05 to 0.95
+	PWMRate    float64 // speed of the LFO sweeping the pulse width in Hz
+	PWMDepth   float64 // how far either side of PulseWidth the LFO sweeps it
+
+	TremoloRate  float64 // speed of the tremolo in Hz
+	TremoloDepth float64 // how far the tremolo dips the volume, 0 for none to 1 for silence
+
+	Clock       *Clock     // tempo the arpeggiator keeps to, shared with the other sounds and the metronome, or nil for its own at DefaultBPM
+	Arp         ArpPattern // arpeggiate the held keys in this order, or ArpOff to play them together
+	ArpDivision float64    // arpeggiator steps per whole note, 16 for sixteenths
+	ArpOctaves  int        // octaves the arpeggio climbs through
+	Sync        bool       // step the arpeggiator with incoming midi clock, Clock following its tempo
+	Latch       bool       // NOTE ON leaves a note held until it's pressed again, NOTE OFF is ignored
+}
+
+// DefaultParams are the settings the command line starts from
+func DefaultParams() *Params {
+	return &Params{
+		Volume:        0.8,
+		Width:         1,
+		BendRange:     2,
+		VibratoRate:   5,
+		CCSlew:        0.01,
+		SubWave:       Square,
+		BandLimit:     true,
+		FMRatio:       1,
+		FMIndex:       1,
+		Cutoff:        20000,
+		HPCutoff:      20,
+		DCBlock:       true,
+		CompRatio:     1,
+		CompThreshold: -18,
+		CompAttack:    0.01,
+		CompRelease:   0.1,
+		Limiter:       true,
+		GateThreshold: math.Inf(-1),
+		PulseWidth:    0.5,
+		PWMRate:       0.5,
+		TremoloRate:   5,
+		ArpDivision:   16,
+		ArpOctaves:    1,
+	}
+}
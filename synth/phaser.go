@@ -0,0 +1,58 @@
+package synth
+
+import "math"
+
+const (
+	phaserMinFreq   = 200.0 // lowest the notches sweep to, in Hz
+	phaserOctaves   = 5.0   // how far above that full depth sweeps them
+	maxPhaserStages = 12
+)
+
+// Phaser sweeps notches through the sound with a chain of all-passes following an LFO
+type Phaser struct {
+	Rate  float64
+	Depth float64
+	Mix   float64
+
+	sampleRate float64
+	lfo        LFO
+	stages     []allPass
+}
+
+// allPass is a first order all-pass stage, passing every frequency but shifting its phase
+type allPass struct {
+	prevInput  float64
+	prevOutput float64
+}
+
+// NewPhaser makes a phaser of between 1 and 12 all-pass stages, 4 being the classic
+func NewPhaser(ac *AudioContext, rate, depth, mix float64, stages int) *Phaser {
+	if stages < 1 {
+		stages = 1
+	} else if stages > maxPhaserStages {
+		stages = maxPhaserStages
+	}
+	return &Phaser{
+		Rate:       rate,
+		Depth:      depth,
+		Mix:        mix,
+		sampleRate: float64(ac.SampleRate),
+		stages:     make([]allPass, stages),
+	}
+}
+
+// Process adds the phasing to one sample
+func (p *Phaser) Process(sample float64) float64 {
+	p.lfo.Rate = p.Rate
+	freq := phaserMinFreq * math.Pow(2, clamp(p.Depth, 0, 1)*phaserOctaves*p.lfo.Advance(1/p.sampleRate))
+	t := math.Tan(math.Pi * math.Min(freq, 0.49*p.sampleRate) / p.sampleRate)
+	coeff := (t - 1) / (t + 1)
+	wet := sample
+	for i := range p.stages {
+		s := &p.stages[i]
+		out := coeff*wet + s.prevInput - coeff*s.prevOutput
+		s.prevInput, s.prevOutput = wet, out
+		wet = out
+	}
+	return (sample + p.Mix*wet) / (1 + p.Mix)
+}
@@ -0,0 +1,44 @@
+package synth
+
+import "fmt"
+
+// NotePriority decides which keys sound when more are held than there are voices
+type NotePriority int
+
+const (
+	PriorityLast NotePriority = iota // the most recently pressed keys
+	PriorityHigh                     // the highest keys
+	PriorityLow                      // the lowest keys
+)
+
+var notePriorityNames = map[NotePriority]string{
+	PriorityLast: "last",
+	PriorityHigh: "high",
+	PriorityLow:  "low",
+}
+
+func (p NotePriority) String() string {
+	return notePriorityNames[p]
+}
+
+// ParseNotePriority looks up a note priority by its name: last, high or low
+func ParseNotePriority(name string) (NotePriority, error) {
+	for p, n := range notePriorityNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return PriorityLast, fmt.Errorf("unknown note priority %q", name)
+}
+
+// beats reports whether note a should sound ahead of note b, a being the more recent
+func (p NotePriority) beats(a, b int64) bool {
+	switch p {
+	case PriorityHigh:
+		return a > b
+	case PriorityLow:
+		return a < b
+	default:
+		return true
+	}
+}
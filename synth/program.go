@@ -0,0 +1,147 @@
+package synth
+
+import "math"
+
+const (
+	bankSilence = 1.0 / (1 << 16) // quieter than the smallest step of 16 bit audio
+	bankTail    = 2.0             // seconds a program that's been left stays silent before it's put aside
+)
+
+// Program is one of a bank's sounds, built along with the bank
+type Program struct {
+	BPM   float64 // tempo the clock changes to when it's picked, 0 to leave it
+	Build func(handler MidiHandler) (SoundGen, *VoicePool)
+}
+
+// builtProgram is a program's sound, played while it's picked or still sounding
+type builtProgram struct {
+	gen    SoundGen
+	pool   *VoicePool
+	queued []Event // for its next poll
+	spare  []Event
+	silent int  // frames since it was left, or last made a sound
+	aside  bool // left and gone quiet, so neither played nor sent anything until it's picked again
+}
+
+// MakeBank builds every program, then plays whichever PROGRAM CHANGE on the channel picks
+func MakeBank(ac *AudioContext, handler MidiHandler, channel int64, clock *Clock, fader *Fader, programs ...Program) SoundGen {
+	bytesPerFrame := ac.NumChannels * ac.BitDepthInBytes
+	chunk := make([]byte, pollFrames(ac)*bytesPerFrame)
+	mix := make([]float64, pollFrames(ac)*ac.NumChannels)
+	tail := int(bankTail * float64(ac.SampleRate))
+	built := make([]*builtProgram, len(programs))
+	for p := range programs {
+		s := &builtProgram{aside: true}
+		s.gen, s.pool = programs[p].Build(func() []Event {
+			events := s.queued
+			s.queued, s.spare = s.spare[:0], events
+			return events
+		})
+		built[p] = s
+	}
+	active := 0
+	var started [16][128]int    // the program each note on each channel last started on
+	var channels [16][130]Event // the last of each controller on each channel, then pitch bend and pressure
+	pick := func(p int) {
+		active = p
+		if programs[p].BPM > 0 {
+			clock.SetBPM(programs[p].BPM)
+		}
+		s := built[p]
+		s.silent = 0
+		if !s.aside {
+			return
+		}
+		s.aside = false
+		for ch := range channels { // catching up on the controllers moved while it was aside
+			for _, e := range channels[ch] {
+				if e.Status != 0 {
+					s.queued = append(s.queued, e)
+				}
+			}
+		}
+	}
+	route := func(e Event) {
+		kind, ch := e.Status&0xF0, e.Status&0x0F
+		to := -1 // all of them
+		switch {
+		case e.Status >= 0xF0:
+		case kind == 0xC0: // PROGRAM CHANGE, which the sounds have no use for
+			if (channel == 0 || ch == channel-1) && int(e.Data1) < len(programs) {
+				pick(int(e.Data1))
+			}
+			return
+		case kind == 0x90 && e.Data2 > 0: // NOTE ON
+			started[ch][e.Data1] = active
+			to = active
+		case kind == 0x80 || kind == 0x90 || kind == 0xA0: // NOTE OFF, however it's sent, and aftertouch
+			to = started[ch][e.Data1]
+		case kind == 0xB0 && e.Data1 == 121: // RESET ALL CONTROLLERS
+			channels[ch] = [130]Event{}
+		case kind == 0xB0:
+			channels[ch][e.Data1] = e
+		case kind == 0xE0:
+			channels[ch][128] = e
+		case kind == 0xD0:
+			channels[ch][129] = e
+		}
+		for p, s := range built {
+			if !s.aside && (to < 0 || to == p) {
+				s.queued = append(s.queued, e)
+			}
+		}
+	}
+	pick(0)
+	untilPoll := 0 // frames, polling the handler on the same frames the programs' translators do
+	return func(buf []byte) (int, error) {
+		n := len(buf) / bytesPerFrame * bytesPerFrame
+		for start := 0; start < n; {
+			if untilPoll == 0 {
+				for _, e := range handler() {
+					route(e)
+				}
+				untilPoll = pollFrames(ac)
+			}
+			end := start + untilPoll*bytesPerFrame
+			if end > n {
+				end = n
+			}
+			untilPoll -= (end - start) / bytesPerFrame
+			frames, samples := chunk[:end-start], mix[:(end-start)/ac.BitDepthInBytes]
+			for i := range samples {
+				samples[i] = 0
+			}
+			for p, s := range built {
+				if s.aside {
+					continue
+				}
+				read, err := s.gen(frames)
+				if err != nil {
+					return start, err
+				}
+				quiet := true
+				for i := 0; i+ac.BitDepthInBytes <= read; i += ac.BitDepthInBytes {
+					sample := ac.sampleAt(frames[i:])
+					samples[i/ac.BitDepthInBytes] += sample
+					quiet = quiet && math.Abs(sample) < bankSilence
+				}
+				if p == active || !quiet || len(s.pool.Voices()) > 0 {
+					s.silent = 0
+				} else if s.silent += read / bytesPerFrame; s.silent >= tail && untilPoll == 0 {
+					s.aside = true // between polls, so it polls again as soon as it's picked
+				}
+			}
+			for i := 0; i < len(samples); i += ac.NumChannels {
+				gain := 1.0
+				if fader != nil {
+					gain = fader.Process(1) // once a frame, so stereo fades in the same time
+				}
+				for c := 0; c < ac.NumChannels; c++ {
+					ac.putSample(buf[start+(i+c)*ac.BitDepthInBytes:], gain*samples[i+c])
+				}
+			}
+			start = end
+		}
+		return n, nil
+	}
+}
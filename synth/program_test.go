@@ -0,0 +1,158 @@
+package synth
+
+import "testing"
+
+// testBank makes a bank of sine programs, counting their builds and renders and keeping what they hear
+func testBank(count int, at map[int][]Event) (SoundGen, []int, []int, [][]Event) {
+	builds, renders, heard := make([]int, count), make([]int, count), make([][]Event, count)
+	programs := []Program{}
+	for p := 0; p < count; p++ {
+		p := p
+		programs = append(programs, Program{Build: func(handler MidiHandler) (SoundGen, *VoicePool) {
+			builds[p]++
+			params := DefaultParams()
+			pool := NewVoicePool(4, Envelope{Attack: 0.001, Decay: 0.01, Sustain: 0.5, Release: 0.01})
+			listen := func() []Event {
+				events := handler()
+				heard[p] = append(heard[p], events...)
+				return events
+			}
+			gen := MakeOscGen(testContext, params, MakeMidiTranslator(testContext, params, listen, pool), Sine)
+			return func(buf []byte) (int, error) {
+				renders[p]++
+				return gen(buf)
+			}, pool
+		}})
+	}
+	return MakeBank(testContext, timeline(at), 0, NewClock(testContext, DefaultBPM), nil, programs...), builds, renders, heard
+}
+
+func TestBankBuildsEveryProgramFirst(t *testing.T) {
+	gen, builds, renders, _ := testBank(3, map[int][]Event{
+		10: {{Status: 0xC0, Data1: 2}},
+		20: {{Status: 0xC0, Data1: 2}, {Status: 0xC0, Data1: 100}}, // again, and past the last
+	})
+	if builds[0] != 1 || builds[1] != 1 || builds[2] != 1 {
+		t.Fatalf("built the programs %v times making the bank, want each once", builds)
+	}
+	gen(make([]byte, 100*pollFrames(testContext)*testContext.BitDepthInBytes))
+	if builds[0] != 1 || builds[1] != 1 || builds[2] != 1 {
+		t.Errorf("built the programs %v times, want none built again while playing", builds)
+	}
+	if renders[1] != 0 {
+		t.Errorf("played a program never picked %d times", renders[1])
+	}
+}
+
+func TestBankSetsAsideProgramsLeft(t *testing.T) {
+	const change = 100
+	gen, _, renders, heard := testBank(2, map[int][]Event{
+		0:          {{Status: 0x90, Data1: 60, Data2: 100}},
+		change:     {{Status: 0xC0, Data1: 1}, {Status: 0x90, Data1: 64, Data2: 100}},
+		change + 5: {{Status: 0x80, Data1: 60}}, // played on the first, so released there
+	})
+	pollBytes := pollFrames(testContext) * testContext.BitDepthInBytes
+	gen(make([]byte, (change+10)*pollBytes))
+	if len(heard[0]) != 2 || heard[0][1].Status != 0x80 {
+		t.Fatalf("the first program heard %v, want its note released after the change", heard[0])
+	}
+	for _, e := range heard[1] {
+		if e.Data1 == 60 {
+			t.Errorf("the second program heard %v, for a note played on the first", e)
+		}
+	}
+	gen(make([]byte, int(bankTail*pollsPerSecond+100)*pollBytes))
+	rendered := renders[0]
+	gen(make([]byte, 100*pollBytes))
+	if renders[0] != rendered {
+		t.Errorf("still rendering the first program, silent and idle %gs after it was left", bankTail)
+	}
+	if renders[1] == 0 {
+		t.Errorf("stopped rendering the program picked")
+	}
+}
+
+func TestBankPicksProgramsAgain(t *testing.T) {
+	pollBytes := pollFrames(testContext) * testContext.BitDepthInBytes
+	aside := int(bankTail*pollsPerSecond + 100)
+	wheel := Event{Status: 0xB0, Data1: 1, Data2: 80}
+	clock := NewClock(testContext, DefaultBPM)
+	builds, renders, heard := 0, 0, []Event{}
+	programs := []Program{{Build: func(handler MidiHandler) (SoundGen, *VoicePool) {
+		params, pool := DefaultParams(), NewVoicePool(1, Envelope{})
+		params.Clock = clock // moving it on while the other's aside
+		return MakeOscGen(testContext, params, MakeMidiTranslator(testContext, params, handler, pool), Sine), pool
+	}}}
+	programs = append(programs, Program{Build: func(handler MidiHandler) (SoundGen, *VoicePool) {
+		builds++
+		params := DefaultParams()
+		params.Clock = clock
+		pool := NewVoicePool(4, Envelope{Attack: 0.001, Decay: 0.01, Sustain: 0.5, Release: 0.01})
+		listen := func() []Event {
+			events := handler()
+			heard = append(heard, events...)
+			return events
+		}
+		gen := MakeOscGen(testContext, params, MakeMidiTranslator(testContext, params, listen, pool), Sine)
+		return func(buf []byte) (int, error) {
+			renders++
+			return gen(buf)
+		}, pool
+	}})
+	gen := MakeBank(testContext, timeline(map[int][]Event{
+		0:            {{Status: 0xC0, Data1: 1}},
+		10:           {{Status: 0xC0, Data1: 0}},
+		aside + 20:   {wheel},
+		2*aside + 20: {{Status: 0xC0, Data1: 1}},
+	}), 0, clock, nil, programs...)
+	gen(make([]byte, (2*aside+10)*pollBytes))
+	rendered := renders
+	gen(make([]byte, 20*pollBytes))
+	if renders == rendered {
+		t.Fatalf("didn't play the program picked again")
+	}
+	if builds != 1 {
+		t.Errorf("built the program %d times, want once however often it's picked", builds)
+	}
+	if len(heard) == 0 || heard[len(heard)-1] != wheel {
+		t.Errorf("the program picked again heard %v, want the mod wheel moved while it was aside", heard)
+	}
+	gen(make([]byte, aside*pollBytes)) // until the first's aside, leaving the clock to this one
+	pickedAt := clock.samples
+	gen(make([]byte, 100*pollBytes))
+	if moved := clock.samples - pickedAt; moved != int64(100*pollFrames(testContext)) {
+		t.Errorf("the clock moved on %d samples playing the program picked again, want %d", moved, 100*pollFrames(testContext))
+	}
+}
+
+func TestBankKeepsProgramsSounding(t *testing.T) {
+	gen, _, renders, _ := testBank(2, map[int][]Event{
+		0:  {{Status: 0x90, Data1: 60, Data2: 100}},
+		10: {{Status: 0xC0, Data1: 1}},
+	})
+	pollBytes := pollFrames(testContext) * testContext.BitDepthInBytes
+	gen(make([]byte, int(bankTail*pollsPerSecond+100)*pollBytes))
+	rendered := renders[0]
+	gen(make([]byte, 100*pollBytes))
+	if renders[0] == rendered {
+		t.Errorf("dropped the first program with its note still held")
+	}
+}
+
+func TestBankProgramsHearTheControllers(t *testing.T) {
+	bend := Event{Status: 0xE0, Data1: 0, Data2: 96}
+	wheel := Event{Status: 0xB0, Data1: 1, Data2: 80}
+	gen, _, _, heard := testBank(3, map[int][]Event{
+		0:  {bend, wheel},
+		5:  {{Status: 0xB0, Data1: 121}, wheel}, // RESET ALL CONTROLLERS forgets the bend
+		10: {{Status: 0xC0, Data1: 1}},
+		15: {{Status: 0xC0, Data1: 2}},
+	})
+	gen(make([]byte, 20*pollFrames(testContext)*testContext.BitDepthInBytes))
+	if len(heard[1]) != 1 || heard[1][0] != wheel {
+		t.Errorf("the program picked heard %v, want the mod wheel as it was left", heard[1])
+	}
+	if len(heard[2]) != 1 || heard[2][0] != wheel {
+		t.Errorf("the next heard %v, want the mod wheel again", heard[2])
+	}
+}
@@ -0,0 +1,50 @@
+package synth
+
+import "io"
+
+// MakeFileHandler plays back a midi file in place of a device, each call moving on by a poll
+func MakeFileHandler(ac *AudioContext, events []TimedEvent) MidiHandler {
+	deltaT := float64(pollFrames(ac)) / float64(ac.SampleRate)
+	now := 0.0
+	next := 0
+	var due []Event
+	return func() []Event {
+		due = due[:0]
+		for next < len(events) && events[next].At <= now {
+			due = append(due, events[next].Event)
+			next++
+		}
+		now += deltaT
+		return due
+	}
+}
+
+// RenderMidiFile runs a midi file's events through the synth as fast as it can, ringing on for tail seconds
+func RenderMidiFile(ac *AudioContext, events []TimedEvent, build func(MidiHandler) SoundGen, w io.Writer, tail float64) error {
+	gen := build(MakeFileHandler(ac, events))
+
+	length := tail
+	if len(events) > 0 {
+		length += events[len(events)-1].At
+	}
+	bytesPerFrame := ac.NumChannels * ac.BitDepthInBytes
+	remaining := int(length*float64(ac.SampleRate)) * bytesPerFrame
+	buf := make([]byte, 512*bytesPerFrame)
+	for remaining > 0 {
+		if remaining < len(buf) {
+			buf = buf[:remaining]
+		}
+		n, err := gen(buf)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		if _, err := w.Write(buf[:n]); err != nil {
+			return err
+		}
+		remaining -= n
+	}
+	return nil
+}
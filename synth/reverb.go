@@ -0,0 +1,77 @@
+package synth
+
+// Freeverb's delay lengths in samples at 44.1kHz, scaled to the sample rate
+var (
+	reverbCombs     = [...]int{1116, 1188, 1277, 1356, 1422, 1491, 1557, 1617}
+	reverbAllPasses = [...]int{556, 441, 341, 225}
+)
+
+const (
+	reverbRate  = 44100.0
+	reverbInput = 0.015 // the combs' input, scaled down as eight of them ring on together
+)
+
+// Reverb is a Schroeder reverb laid out like Freeverb, its Size, Damp and Mix from 0 to 1
+type Reverb struct {
+	Size float64
+	Damp float64
+	Mix  float64
+
+	combs     [len(reverbCombs)]reverbComb
+	allPasses [len(reverbAllPasses)]reverbAllPass
+}
+
+// reverbComb is a feedback comb filter with a low-pass in its loop, so each pass round is duller
+type reverbComb struct {
+	buf    []float64
+	idx    int
+	filter float64 // the low-pass's last output
+}
+
+// reverbAllPass passes every frequency at the same level but smears their timing
+type reverbAllPass struct {
+	buf []float64
+	idx int
+}
+
+// NewReverb makes a reverb, its delay lines sized for the context's sample rate
+func NewReverb(ac *AudioContext, size, damp, mix float64) *Reverb {
+	r := &Reverb{Size: size, Damp: damp, Mix: mix}
+	scale := float64(ac.SampleRate) / reverbRate
+	length := func(samples int) int {
+		if n := int(float64(samples) * scale); n > 1 {
+			return n
+		}
+		return 1
+	}
+	for i, n := range reverbCombs {
+		r.combs[i].buf = make([]float64, length(n))
+	}
+	for i, n := range reverbAllPasses {
+		r.allPasses[i].buf = make([]float64, length(n))
+	}
+	return r
+}
+
+// Process adds the reverb to one sample
+func (r *Reverb) Process(sample float64) float64 {
+	feedback := 0.7 + 0.28*clamp(r.Size, 0, 1) // Freeverb's room size range, never quite ringing on
+	damp := 0.4 * clamp(r.Damp, 0, 1)
+	wet := 0.0
+	for i := range r.combs {
+		c := &r.combs[i]
+		out := c.buf[c.idx]
+		c.filter = out*(1-damp) + c.filter*damp
+		c.buf[c.idx] = sample*reverbInput + c.filter*feedback
+		c.idx = (c.idx + 1) % len(c.buf)
+		wet += out
+	}
+	for i := range r.allPasses {
+		a := &r.allPasses[i]
+		delayed := a.buf[a.idx]
+		a.buf[a.idx] = wet + delayed*0.5
+		a.idx = (a.idx + 1) % len(a.buf)
+		wet = delayed - wet
+	}
+	return sample*(1-r.Mix) + wet*r.Mix
+}
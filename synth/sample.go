@@ -0,0 +1,44 @@
+package synth
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// SampleFormat is how the samples in the generator's PCM are encoded
+type SampleFormat int
+
+const (
+	SampleInt   SampleFormat = iota // signed little endian integers, BitDepthInBytes wide, 2 or 3
+	SampleFloat                     // little endian 32 bit IEEE floats, BitDepthInBytes 4
+)
+
+// full scale of 24 bit PCM, kept a step short of the top as the 16 bit is
+const maxInt24 = 1<<23 - 1
+
+// putSample writes a sample to buf in the context's format, saturating rather than wrapping
+func (ac *AudioContext) putSample(buf []byte, sample float64) {
+	switch {
+	case ac.Format == SampleFloat:
+		binary.LittleEndian.PutUint32(buf, math.Float32bits(float32(clamp(sample, -1, 1))))
+	case ac.BitDepthInBytes == 3:
+		b := int32(clamp(sample, -1, 1) * (maxInt24 - 1))
+		buf[0], buf[1], buf[2] = byte(b), byte(b>>8), byte(b>>16)
+	default:
+		b := toInt16(sample)
+		buf[0], buf[1] = byte(b), byte(b>>8)
+	}
+}
+
+// sampleAt reads a sample back from buf in the context's format, full scale being 1
+func (ac *AudioContext) sampleAt(buf []byte) float64 {
+	switch {
+	case ac.Format == SampleFloat:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(buf)))
+	case ac.BitDepthInBytes == 3:
+		b := int32(uint32(buf[0])<<8|uint32(buf[1])<<16|uint32(buf[2])<<24) >> 8 // sign extended
+		return float64(b) / (maxInt24 - 1)
+	default:
+		return float64(int16(binary.LittleEndian.Uint16(buf))) / (math.MaxInt16 - 1)
+	}
+}
@@ -0,0 +1,99 @@
+package synth
+
+import (
+	"math"
+	"testing"
+)
+
+func TestToInt16Saturates(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want int16
+	}{
+		{0, 0},
+		{1, math.MaxInt16 - 1},
+		{2, math.MaxInt16 - 1},
+		{10, math.MaxInt16 - 1},
+		{math.Inf(1), math.MaxInt16 - 1},
+		{-1, -(math.MaxInt16 - 1)},
+		{-2, -(math.MaxInt16 - 1)},
+		{-10, -(math.MaxInt16 - 1)},
+		{math.Inf(-1), -(math.MaxInt16 - 1)},
+	}
+	for _, tt := range tests {
+		if got := toInt16(tt.in); got != tt.want {
+			t.Errorf("toInt16(%g) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPutSampleSaturates(t *testing.T) {
+	contexts := []*AudioContext{
+		{SampleRate: 48000, NumChannels: 1, BitDepthInBytes: 2},
+		{SampleRate: 48000, NumChannels: 1, BitDepthInBytes: 3},
+		{SampleRate: 48000, NumChannels: 1, BitDepthInBytes: 4, Format: SampleFloat},
+	}
+	for _, ac := range contexts {
+		buf := make([]byte, ac.BitDepthInBytes)
+		for _, in := range []float64{2, 10, -2, -10} {
+			ac.putSample(buf, in)
+			if got := ac.sampleAt(buf); math.Abs(got) > 1 || (got > 0) != (in > 0) || math.Abs(got) < 0.999 {
+				t.Errorf("%d bytes: %g came back as %g, want it saturated at full scale", ac.BitDepthInBytes, in, got)
+			}
+		}
+	}
+}
+
+func TestPutSampleLayout(t *testing.T) {
+	tests := []struct {
+		name   string
+		ac     *AudioContext
+		sample float64
+		want   []byte
+	}{
+		{"16 bit", &AudioContext{BitDepthInBytes: 2}, 0.5, []byte{0xFF, 0x3F}},
+		{"16 bit negative", &AudioContext{BitDepthInBytes: 2}, -0.5, []byte{0x01, 0xC0}},
+		{"16 bit full scale", &AudioContext{BitDepthInBytes: 2}, 1, []byte{0xFE, 0x7F}},
+		{"24 bit", &AudioContext{BitDepthInBytes: 3}, 0.5, []byte{0xFF, 0xFF, 0x3F}},
+		{"24 bit negative", &AudioContext{BitDepthInBytes: 3}, -0.5, []byte{0x01, 0x00, 0xC0}},
+		{"24 bit full scale", &AudioContext{BitDepthInBytes: 3}, -1, []byte{0x02, 0x00, 0x80}},
+		{"float", &AudioContext{BitDepthInBytes: 4, Format: SampleFloat}, 0.5, []byte{0x00, 0x00, 0x00, 0x3F}},
+		{"float negative", &AudioContext{BitDepthInBytes: 4, Format: SampleFloat}, -1, []byte{0x00, 0x00, 0x80, 0xBF}},
+	}
+	for _, tt := range tests {
+		buf := make([]byte, tt.ac.BitDepthInBytes+1) // one over, which mustn't be touched
+		buf[len(buf)-1] = 0xAA
+		tt.ac.putSample(buf, tt.sample)
+		if string(buf[:len(tt.want)]) != string(tt.want) || buf[len(buf)-1] != 0xAA {
+			t.Errorf("%s: %g written as % x, want % x", tt.name, tt.sample, buf[:len(buf)-1], tt.want)
+		}
+	}
+}
+
+func TestGeneratorFrameLayout(t *testing.T) {
+	for _, ac := range []*AudioContext{
+		{SampleRate: 48000, NumChannels: 2, BitDepthInBytes: 2},
+		{SampleRate: 48000, NumChannels: 2, BitDepthInBytes: 3},
+		{SampleRate: 48000, NumChannels: 2, BitDepthInBytes: 4, Format: SampleFloat},
+	} {
+		params := DefaultParams()
+		params.Pan = -1 // everything on the left
+		pool := NewVoicePool(1, Envelope{Attack: 0.001, Sustain: 1, Release: 0.1})
+		gen := MakeOscGen(ac, params, MakeMidiTranslator(ac, params, scripted([]Event{{Status: 0x90, Data1: 69, Data2: 127}}), pool), Sine)
+		frames := 480
+		buf := make([]byte, frames*ac.NumChannels*ac.BitDepthInBytes+1)
+		n, err := gen(buf)
+		if err != nil || n != len(buf)-1 {
+			t.Fatalf("%d bytes: filled %d of a %d byte buffer, %v", ac.BitDepthInBytes, n, len(buf), err)
+		}
+		left, right := 0.0, 0.0
+		for i := 0; i < frames; i++ {
+			frame := buf[i*ac.NumChannels*ac.BitDepthInBytes:]
+			left = math.Max(left, math.Abs(ac.sampleAt(frame)))
+			right = math.Max(right, math.Abs(ac.sampleAt(frame[ac.BitDepthInBytes:])))
+		}
+		if left < 0.1 || right > 1e-6 {
+			t.Errorf("%d bytes: panned left the channels peaked at %g and %g", ac.BitDepthInBytes, left, right)
+		}
+	}
+}
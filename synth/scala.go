@@ -0,0 +1,127 @@
+package synth
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Scale is a tuning from a Scala .scl file, a ratio per degree from the root and the period it repeats at
+type Scale struct {
+	Description string
+	Ratios      []float64
+	Period      float64
+}
+
+// ReadScala loads a Scala .scl file
+func ReadScala(path string) (*Scale, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseScala(f)
+}
+
+// ParseScala parses the Scala format, its pitches in cents or ratios, the last being the period
+func ParseScala(r io.Reader) (*Scale, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("scale is missing its description or pitch count")
+	}
+
+	count, err := strconv.Atoi(firstField(lines[1]))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("bad pitch count %q", lines[1])
+	}
+	pitches := []float64{}
+	for _, line := range lines[2:] {
+		if line == "" {
+			continue
+		}
+		ratio, err := parseScalaPitch(firstField(line))
+		if err != nil {
+			return nil, err
+		}
+		pitches = append(pitches, ratio)
+		if len(pitches) == count {
+			break
+		}
+	}
+	if len(pitches) < count {
+		return nil, fmt.Errorf("scale has %d pitches, expected %d", len(pitches), count)
+	}
+	return &Scale{
+		Description: lines[0],
+		Ratios:      append([]float64{1}, pitches[:count-1]...),
+		Period:      pitches[count-1],
+	}, nil
+}
+
+// parseScalaPitch reads a pitch as a frequency ratio
+func parseScalaPitch(pitch string) (float64, error) {
+	if strings.Contains(pitch, ".") {
+		cents, err := strconv.ParseFloat(pitch, 64)
+		if err != nil {
+			return 0, fmt.Errorf("bad pitch %q", pitch)
+		}
+		return math.Pow(2, cents/1200), nil
+	}
+	num, den := pitch, "1"
+	if i := strings.Index(pitch, "/"); i >= 0 {
+		num, den = pitch[:i], pitch[i+1:]
+	}
+	n, err := strconv.ParseUint(num, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad pitch %q", pitch)
+	}
+	d, err := strconv.ParseUint(den, 10, 64)
+	if err != nil || n == 0 || d == 0 {
+		return 0, fmt.Errorf("bad pitch %q", pitch)
+	}
+	return float64(n) / float64(d), nil
+}
+
+func firstField(line string) string {
+	if fields := strings.Fields(line); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// Freq is the frequency of the note in the scale, laid out so the root note plays at rootFreq
+func (s *Scale) Freq(note, root int64, rootFreq float64) float64 {
+	steps := note - root
+	degrees := int64(len(s.Ratios))
+	periods := steps / degrees
+	degree := steps % degrees
+	if degree < 0 { // round down below the root
+		degree += degrees
+		periods--
+	}
+	return rootFreq * math.Pow(s.Period, float64(periods)) * s.Ratios[degree]
+}
+
+// NoteMap tunes every midi note through the scale, for a pool's SetNoteMap
+func (s *Scale) NoteMap(root int64, rootFreq float64) []float64 {
+	notes := make([]float64, 128)
+	for n := range notes {
+		notes[n] = s.Freq(int64(n), root, rootFreq)
+	}
+	return notes
+}
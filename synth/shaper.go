@@ -0,0 +1,12 @@
+package synth
+
+import "math"
+
+// softClip drives a sample into a normalized tanh curve, 0 leaving it alone
+func softClip(sample, drive float64) float64 {
+	if drive <= 0 {
+		return sample
+	}
+	gain := 1 + drive
+	return math.Tanh(gain*sample) / math.Tanh(gain)
+}
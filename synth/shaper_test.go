@@ -0,0 +1,37 @@
+package synth
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSoftClipBounded(t *testing.T) {
+	for _, drive := range []float64{0.1, 1, 5, 100} {
+		for _, in := range []float64{-10, -2, -1, 1, 2, 10} {
+			out := softClip(in, drive)
+			if math.Abs(out) > 1/math.Tanh(1+drive)+1e-9 || math.IsNaN(out) {
+				t.Errorf("softClip(%g, %g) = %g, past the curve's ceiling", in, drive, out)
+			}
+			if s := toInt16(out); (in > 0) != (s > 0) {
+				t.Errorf("softClip(%g, %g) came out of toInt16 as %d, wrapped around", in, drive, s)
+			}
+		}
+	}
+}
+
+func TestSoftClipFullScale(t *testing.T) {
+	for _, drive := range []float64{0.1, 1, 5} {
+		if out := softClip(1, drive); math.Abs(out-1) > 1e-9 {
+			t.Errorf("softClip(1, %g) = %g, full scale should stay full scale", drive, out)
+		}
+		if out := softClip(10, drive); out < 1 || out > 1/math.Tanh(1+drive) {
+			t.Errorf("softClip(10, %g) = %g, want it just over full scale", drive, out)
+		}
+	}
+}
+
+func TestSoftClipOff(t *testing.T) {
+	if out := softClip(10, 0); out != 10 {
+		t.Errorf("softClip(10, 0) = %g, no drive should leave the sample alone", out)
+	}
+}
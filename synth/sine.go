@@ -0,0 +1,25 @@
+package synth
+
+import "math"
+
+// sineTableSize is the steps in a cycle of the sine table, interpolated to within 3e-7
+const sineTableSize = 4096
+
+// sineTable holds one cycle of a sine, plus the first entry again on the end to interpolate into
+var sineTable [sineTableSize + 1]float64
+
+func init() {
+	for i := range sineTable {
+		sineTable[i] = math.Sin(2 * math.Pi * float64(i) / sineTableSize)
+	}
+}
+
+// sine is sin(2π·phase) for a phase in cycles, read from the table
+func sine(phase float64) float64 {
+	pos := (phase - math.Floor(phase)) * sineTableSize
+	i := int(pos)
+	if i >= sineTableSize { // a phase a hair below a whole cycle can round up to it
+		return 0
+	}
+	return sineTable[i] + (sineTable[i+1]-sineTable[i])*(pos-float64(i))
+}
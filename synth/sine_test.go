@@ -0,0 +1,54 @@
+package synth
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSineTable(t *testing.T) {
+	worst := 0.0
+	for i := 0; i < 100000; i++ {
+		phase := float64(i)/100000*3 - 1 // over a few cycles, negative ones too
+		worst = math.Max(worst, math.Abs(sine(phase)-math.Sin(2*math.Pi*phase)))
+	}
+	if worst > 1e-6 {
+		t.Errorf("the sine table is up to %g out from math.Sin", worst)
+	}
+	if s := sine(math.Nextafter(1, 0)); math.Abs(s) > 1e-6 {
+		t.Errorf("a hair below a whole cycle gave %g, want about 0", s)
+	}
+}
+
+// TestFilterCoefficientCache checks cached coefficients against ones recomputed every sample
+func TestFilterCoefficientCache(t *testing.T) {
+	cached := NewFilter(testContext, 1000, 0.5)
+	reference := NewFilter(testContext, 1000, 0.5)
+	for i := 0; i < testContext.SampleRate/10; i++ {
+		cutoff := 1000.0
+		if i/1000%2 == 1 {
+			cutoff = 200 + float64(i%1000)*5
+		}
+		cached.Cutoff, reference.Cutoff = cutoff, cutoff
+		reference.lastCutoff = -1
+		in := sine(440 * float64(i) / float64(testContext.SampleRate))
+		if got, want := cached.Process(in), reference.Process(in); got != want {
+			t.Fatalf("sample %d at %gHz: %g from the cached coefficients, want %g", i, cutoff, got, want)
+		}
+	}
+}
+
+func BenchmarkSineTable(b *testing.B) {
+	sum := 0.0
+	for i := 0; i < b.N; i++ {
+		sum += sine(float64(i) * 0.001)
+	}
+	_ = sum
+}
+
+func BenchmarkMathSin(b *testing.B) {
+	sum := 0.0
+	for i := 0; i < b.N; i++ {
+		sum += math.Sin(2 * math.Pi * float64(i) * 0.001)
+	}
+	_ = sum
+}
@@ -0,0 +1,215 @@
+package synth
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// TimedEvent is a midi event from a file, At seconds from the start of the song
+type TimedEvent struct {
+	At    float64
+	Event Event
+}
+
+// tickEvent is a parsed but not yet timed event, tempo changes among them
+type tickEvent struct {
+	tick  uint64
+	order int // keeps events on the same tick in file order
+	tempo uint32
+	event Event
+}
+
+// ReadMidiFile parses a standard midi file into its channel messages, timed in seconds by its tempo
+func ReadMidiFile(path string) ([]TimedEvent, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(data)
+
+	var header struct {
+		Format   uint16
+		Tracks   uint16
+		Division uint16
+	}
+	if err := readChunk(r, "MThd", &header); err != nil {
+		return nil, err
+	}
+
+	var events []tickEvent
+	for i := 0; i < int(header.Tracks); i++ {
+		var track []byte
+		if err := readChunk(r, "MTrk", &track); err != nil {
+			return nil, fmt.Errorf("track %d: %s", i, err.Error())
+		}
+		if events, err = parseTrack(track, events); err != nil {
+			return nil, fmt.Errorf("track %d: %s", i, err.Error())
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].tick != events[j].tick {
+			return events[i].tick < events[j].tick
+		}
+		return events[i].order < events[j].order
+	})
+	return timeEvents(events, header.Division), nil
+}
+
+// readChunk reads a chunk of the given type, decoding it into v or, for a *[]byte, copying its body
+func readChunk(r *bytes.Reader, chunkType string, v interface{}) error {
+	var id [4]byte
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &id); err != nil {
+		return fmt.Errorf("reading %s: %s", chunkType, err.Error())
+	}
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return fmt.Errorf("reading %s: %s", chunkType, err.Error())
+	}
+	if string(id[:]) != chunkType {
+		return fmt.Errorf("expected %s chunk, got %q", chunkType, id[:])
+	}
+	if int64(length) > int64(r.Len()) {
+		return fmt.Errorf("%s chunk is truncated", chunkType)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return err
+	}
+	if b, ok := v.(*[]byte); ok {
+		*b = body
+		return nil
+	}
+	return binary.Read(bytes.NewReader(body), binary.BigEndian, v)
+}
+
+var errTruncated = errors.New("truncated event")
+
+// parseTrack appends the channel messages and tempo changes of one track
+func parseTrack(track []byte, events []tickEvent) ([]tickEvent, error) {
+	r := bytes.NewReader(track)
+	tick := uint64(0)
+	status := byte(0) // running status
+	for r.Len() > 0 {
+		delta, err := readVarLen(r)
+		if err != nil {
+			return nil, err
+		}
+		tick += uint64(delta)
+
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, errTruncated
+		}
+		switch {
+		case b == 0xFF: // meta event
+			kind, err := r.ReadByte()
+			if err != nil {
+				return nil, errTruncated
+			}
+			body, err := readVarBytes(r)
+			if err != nil {
+				return nil, err
+			}
+			if kind == 0x51 && len(body) == 3 { // set tempo, microseconds per quarter note
+				tempo := uint32(body[0])<<16 | uint32(body[1])<<8 | uint32(body[2])
+				events = append(events, tickEvent{tick: tick, order: len(events), tempo: tempo})
+			}
+			if kind == 0x2F { // end of track
+				return events, nil
+			}
+			continue
+		case b == 0xF0 || b == 0xF7: // sysex, not used by the synth
+			if _, err := readVarBytes(r); err != nil {
+				return nil, err
+			}
+			continue
+		case b&0x80 != 0:
+			status = b
+		default:
+			if status == 0 {
+				return nil, errors.New("data byte without a status")
+			}
+			r.UnreadByte()
+		}
+
+		e := Event{Status: int64(status)}
+		if e.Data1, err = readDataByte(r); err != nil {
+			return nil, err
+		}
+		if kind := status & 0xF0; kind != 0xC0 && kind != 0xD0 { // program change and channel pressure have one data byte
+			if e.Data2, err = readDataByte(r); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, tickEvent{tick: tick, order: len(events), event: e})
+	}
+	return events, nil
+}
+
+// timeEvents converts ticks to seconds, following tempo changes as they come
+func timeEvents(events []tickEvent, division uint16) []TimedEvent {
+	tempo := 500000.0 // 120 bpm until told otherwise
+	ticksPerQuarter := float64(division)
+	secondsPerTick := func() float64 { return tempo / 1e6 / ticksPerQuarter }
+	if division&0x8000 != 0 { // SMPTE, frames per second and ticks per frame
+		fps := float64(-int8(division >> 8))
+		ticksPerSecond := fps * float64(division&0xFF)
+		secondsPerTick = func() float64 { return 1 / ticksPerSecond }
+	}
+
+	timed := []TimedEvent{}
+	lastTick := uint64(0)
+	now := 0.0
+	for _, e := range events {
+		now += float64(e.tick-lastTick) * secondsPerTick()
+		lastTick = e.tick
+		if e.tempo != 0 {
+			tempo = float64(e.tempo)
+			continue
+		}
+		e.event.Timestamp = int64(now * 1000)
+		timed = append(timed, TimedEvent{At: now, Event: e.event})
+	}
+	return timed
+}
+
+func readVarLen(r *bytes.Reader) (uint32, error) {
+	value := uint32(0)
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, errTruncated
+		}
+		value = value<<7 | uint32(b&0x7F)
+		if b&0x80 == 0 {
+			return value, nil
+		}
+	}
+	return 0, errors.New("variable length quantity too long")
+}
+
+func readVarBytes(r *bytes.Reader) ([]byte, error) {
+	length, err := readVarLen(r)
+	if err != nil {
+		return nil, err
+	}
+	if int64(length) > int64(r.Len()) {
+		return nil, errTruncated
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return body, err
+}
+
+func readDataByte(r *bytes.Reader) (int64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, errTruncated
+	}
+	return int64(b & 0x7F), nil
+}
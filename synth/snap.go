@@ -0,0 +1,81 @@
+package synth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// scaleSteps are the semitones above the root of each scale a note can snap to
+var scaleSteps = map[string][]int64{
+	"chromatic":       {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	"major":           {0, 2, 4, 5, 7, 9, 11},
+	"minor":           {0, 2, 3, 5, 7, 8, 10},
+	"harmonicminor":   {0, 2, 3, 5, 7, 8, 11},
+	"dorian":          {0, 2, 3, 5, 7, 9, 10},
+	"mixolydian":      {0, 2, 4, 5, 7, 9, 10},
+	"pentatonic":      {0, 2, 4, 7, 9},
+	"minorpentatonic": {0, 3, 5, 7, 10},
+	"blues":           {0, 3, 5, 6, 7, 10},
+}
+
+// pitchClasses are the semitones above C of the note names a root can be given as
+var pitchClasses = map[string]int64{
+	"c": 0, "c#": 1, "db": 1, "d": 2, "d#": 3, "eb": 3, "e": 4, "f": 5, "f#": 6, "gb": 6,
+	"g": 7, "g#": 8, "ab": 8, "a": 9, "a#": 10, "bb": 10, "b": 11,
+}
+
+// SnapScale is a key incoming notes snap into, chromatic as the zero value
+type SnapScale struct {
+	inScale [12]bool // by semitones above C
+	name    string
+}
+
+// ParseSnapScale reads a root and a scale name, like "D minor" or "F#:pentatonic"
+func ParseSnapScale(s string) (SnapScale, error) {
+	parts := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return r == ' ' || r == ':' })
+	if len(parts) == 1 && parts[0] == "chromatic" {
+		return SnapScale{}, nil
+	}
+	if len(parts) != 2 {
+		return SnapScale{}, fmt.Errorf("%q is not a root and scale, like \"D minor\"", s)
+	}
+	root, ok := pitchClasses[parts[0]]
+	if !ok {
+		return SnapScale{}, fmt.Errorf("unknown root note %q", parts[0])
+	}
+	steps, ok := scaleSteps[parts[1]]
+	if !ok {
+		return SnapScale{}, fmt.Errorf("unknown scale %q", parts[1])
+	}
+	if parts[1] == "chromatic" {
+		return SnapScale{}, nil
+	}
+	snap := SnapScale{name: s}
+	for _, step := range steps {
+		snap.inScale[(root+step)%12] = true
+	}
+	return snap, nil
+}
+
+func (s SnapScale) String() string {
+	if s.name == "" {
+		return "chromatic"
+	}
+	return s.name
+}
+
+// snap moves a note off the scale to the nearest note in it, the lower one when it's between two
+func (s SnapScale) snap(note int64) int64 {
+	if s.name == "" {
+		return note
+	}
+	for distance := int64(0); distance < 12; distance++ {
+		if below := note - distance; s.inScale[((below%12)+12)%12] {
+			return below
+		}
+		if above := note + distance; s.inScale[((above%12)+12)%12] {
+			return above
+		}
+	}
+	return note
+}
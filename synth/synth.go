@@ -0,0 +1,490 @@
+// Package synth is a minimal real-time synthesizer, from midi events to voices to PCM
+package synth
+
+import "math"
+
+// AudioContext describes the PCM format the generator writes
+type AudioContext struct {
+	SampleRate      int
+	NumChannels     int
+	BitDepthInBytes int
+	Format          SampleFormat // integers unless set to floats
+}
+
+// Event is a single midi message, laid out like portmidi's so handlers can copy them straight across
+type Event struct {
+	Timestamp int64 // milliseconds
+	Status    int64
+	Data1     int64
+	Data2     int64
+}
+
+// Effect processes the mixed output one sample at a time, carrying whatever state it needs between calls
+type Effect interface {
+	Process(sample float64) float64
+}
+
+type MidiHandler func() []Event             // pulls and returns a list of midi events, which may be reused by the next call
+type MidiTranslator func() []*Voice         // translates those events into the voices a sound generator should play
+type SoundGen func(buf []byte) (int, error) // generates the waveform and reads it to a buffer
+
+func (sg SoundGen) Read(buf []byte) (int, error) {
+	return sg(buf)
+}
+
+// the square's duty cycle is kept clear of the extremes, where the pulse would vanish
+const (
+	minPulseWidth = 0.05
+	maxPulseWidth = 0.95
+)
+
+// maxSyncRatio is as far above the master as a controller sweeps a hard synced oscillator
+const maxSyncRatio = 8
+
+// vibratoDepth is how far, in semitones, the vibrato swings with the mod wheel all the way up
+const vibratoDepth = 0.5
+
+// the registered parameters a controller selects with CC101 and CC100, for data entry to set
+const (
+	rpnBendRange = 0x0000 // semitones on the data entry MSB and cents on the LSB
+	rpnNull      = 0x3FFF // none selected, so data entry is ignored
+)
+
+// pollsPerSecond is how often the translator polls its handler for midi
+const pollsPerSecond = 1000
+
+// pollFrames is the number of samples between the translator's polls of its handler
+func pollFrames(ac *AudioContext) int {
+	if frames := ac.SampleRate / pollsPerSecond; frames > 1 {
+		return frames
+	}
+	return 1
+}
+
+// MakeMidiTranslator builds a function to play midi events on the pool, called once a sample
+func MakeMidiTranslator(ac *AudioContext, params *Params, handler MidiHandler, pool *VoicePool) MidiTranslator {
+	deltaT := float64(1) / float64(ac.SampleRate)
+	glide := 1 - math.Exp(-deltaT/0.01) // ~10ms smoothing for the bend wheel
+	bend := 1.0                         // frequency multiplier currently applied
+	bendTarget := 1.0                   // frequency multiplier the wheel is asking for
+	wheel := 0.0                        // where the wheel is, -1 to 1
+	rpn := int64(rpnNull)               // the registered parameter data entry sets
+	setBend := func() {
+		bendTarget = math.Pow(2, wheel*params.BendRange/12)
+	}
+	modWheel := 0.0
+	channelPressure := 0.0
+	lfo := LFO{}
+	arp := newArpeggiator(pool)
+	external := midiClock{running: true} // a clock that's already going counts from whenever it's heard
+	clock := params.Clock
+	if clock == nil {
+		clock = NewClock(ac, DefaultBPM)
+	}
+	played := clock.samples          // samples since the clock began, moving it on, for a sound built late too
+	lagging := int64(pollFrames(ac)) // as far behind as another sound played first can leave it
+	untilPoll := 0
+	latched := map[int64]bool{} // notes left on by latch mode, until they're pressed again
+	noteOn := func(note int64, velocity float64) {
+		if params.Arp != ArpOff {
+			arp.noteOn(note, velocity)
+		} else {
+			pool.NoteOn(note, velocity)
+		}
+	}
+	noteOff := func(note int64) {
+		if params.Arp != ArpOff {
+			arp.noteOff(note)
+		} else {
+			pool.NoteOff(note)
+		}
+	}
+	apply := func(target CCTarget, value float64) {
+		if target == CCVibrato {
+			modWheel = value
+		} else {
+			target.set(params, pool, value)
+		}
+	}
+	slews := map[CCTarget]*slew{}
+	control := func(target CCTarget, value float64) {
+		if params.CCSlew <= 0 || !target.smoothed() {
+			apply(target, value)
+			return
+		}
+		if s, ok := slews[target]; ok {
+			s.target = value
+		} else { // nothing to glide from until the knob has been moved once
+			slews[target] = &slew{value: value, target: value}
+			apply(target, value)
+		}
+	}
+	return func() []*Voice {
+		var events []Event
+		if untilPoll--; untilPoll <= 0 {
+			untilPoll = pollFrames(ac)
+			events = handler()
+			if params.Controls != nil {
+				params.Controls.drain(control)
+			}
+		}
+		for i := range events {
+			e := events[i]
+			switch e.Status {
+			case 0xF8: // TIMING CLOCK
+				if ticks, running := external.tick(e.Timestamp); running && params.Sync {
+					if bpm := external.bpm(); bpm > 0 {
+						clock.SetBPM(bpm)
+					}
+					if params.Arp != ArpOff {
+						arp.tick(params, ticks)
+					}
+				}
+				continue
+			case 0xFA: // START
+				external.start()
+				if params.Sync {
+					clock.restart()
+				}
+				arp.restart()
+				continue
+			case 0xFB: // CONTINUE
+				external.running = true
+				continue
+			case 0xFC: // STOP
+				external.running = false
+				continue
+			}
+			if params.Channel != 0 && e.Status&0x0F != params.Channel-1 {
+				continue // another channel's message
+			}
+			kind := e.Status & 0xF0
+			if kind == 0x90 && e.Data2 == 0 {
+				kind = 0x80 // plenty of controllers send NOTE ON at zero velocity instead of NOTE OFF
+			}
+			switch kind {
+			case 0x90: // NOTE ON
+				if sound, ok := params.Samples[e.Data1]; ok { // a pad, played as it is rather than transposed
+					pool.Trigger(e.Data1, params.VelocityCurve.velocity(e.Data2), sound)
+				} else if note, ok := transpose(params, e.Data1); ok && latched[note] {
+					delete(latched, note) // pressed again, let go of it
+					noteOff(note)
+				} else if ok {
+					if params.Latch {
+						latched[note] = true
+					}
+					noteOn(note, params.VelocityCurve.velocity(e.Data2))
+				}
+			case 0x80: // NOTE OFF
+				if _, ok := params.Samples[e.Data1]; ok {
+					continue // one-shots play to the end whenever the pad is let go
+				}
+				if note, ok := transpose(params, e.Data1); ok && !latched[note] {
+					noteOff(note)
+				}
+			case 0xA0: // POLYPHONIC AFTERTOUCH
+				if note, ok := transpose(params, e.Data1); ok {
+					pool.SetPressure(note, float64(e.Data2)/127.0)
+				}
+			case 0xD0: // CHANNEL AFTERTOUCH
+				channelPressure = float64(e.Data1) / 127.0
+			case 0xE0: // PITCH BEND
+				value := e.Data2<<7 | e.Data1 // 14-bit, centered at 0x2000
+				wheel = float64(value-0x2000) / 0x2000
+				setBend()
+			case 0xB0: // CONTROL CHANGE
+				if target, ok := params.CCMap[e.Data1]; ok { // assigned knobs take over from the fixed ones
+					control(target, float64(e.Data2)/127.0)
+					continue
+				}
+				switch e.Data1 {
+				case 1: // MOD WHEEL
+					control(CCVibrato, float64(e.Data2)/127.0)
+				case 7: // CHANNEL VOLUME
+					control(CCVolume, float64(e.Data2)/127.0)
+				case 10: // PAN
+					control(CCPan, math.Max(0, 1+float64(e.Data2-64)/63.0)/2) // centred on 64, right at 127
+				case 101: // RPN MSB
+					rpn = e.Data2<<7 | rpn&0x7F
+				case 100: // RPN LSB
+					rpn = rpn&^0x7F | e.Data2
+				case 99, 98: // NRPN, none of which are supported, so data entry goes nowhere
+					rpn = rpnNull
+				case 6: // DATA ENTRY MSB
+					if rpn == rpnBendRange {
+						params.BendRange = float64(e.Data2)
+						setBend()
+					}
+				case 38: // DATA ENTRY LSB
+					if rpn == rpnBendRange {
+						params.BendRange = math.Floor(params.BendRange) + float64(e.Data2)/100
+						setBend()
+					}
+				case 64: // SUSTAIN PEDAL
+					pool.SetSustain(e.Data2 >= 64)
+				case 66: // SOSTENUTO PEDAL
+					pool.SetSostenuto(e.Data2 >= 64)
+				case 120: // ALL SOUND OFF
+					latched = map[int64]bool{}
+					arp.clear()
+					pool.AllSoundOff()
+				case 123: // ALL NOTES OFF
+					latched = map[int64]bool{}
+					arp.clear()
+					pool.AllNotesOff()
+				}
+			}
+		}
+		for target, s := range slews {
+			if s.advance(deltaT, params.CCSlew) {
+				apply(target, s.value)
+			}
+		}
+		if played < clock.samples-lagging { // left unplayed a while, by a bank, so catching the clock up
+			played = clock.samples
+		}
+		clock.reach(played)
+		played++
+		if params.Arp != ArpOff {
+			arp.advance(params, clock, deltaT)
+		}
+
+		// the wheel only reports every few milliseconds, glide between reports rather than stepping
+		bend += (bendTarget - bend) * glide
+
+		lfo.Rate = params.VibratoRate
+		swing := 2*lfo.Advance(deltaT) - 1
+		vibrato := math.Pow(2, modWheel*vibratoDepth*swing/12)
+
+		voices := pool.Voices()
+		for _, v := range voices {
+			freq, ok := pool.noteFreq(v.Note)
+			if !ok { // the tuning was swapped for a shorter one under the note, let it go where it is
+				freq, v.Gate = v.baseFreq, false
+			}
+			v.baseFreq = v.glideFreq(params, freq, deltaT)
+			v.Freq = v.baseFreq * bend * vibrato
+			if params.Analog > 0 {
+				v.Freq *= v.analogPitch(params.Analog, pool.rng, deltaT)
+				v.levelDrift.advance(pool.rng, deltaT)
+			}
+			v.Pressure = math.Max(v.notePressure, channelPressure)
+		}
+		return voices
+	}
+}
+
+// transpose shifts and snaps a note by the params, reporting false if it leaves the midi range
+func transpose(params *Params, note int64) (int64, bool) {
+	note = params.Snap.snap(note + params.Transpose)
+	return note, 0 <= note && note <= 127
+}
+
+// MakeOscGen builds the generator, summing every voice through its filter and envelope, then the effects
+func MakeOscGen(ac *AudioContext, params *Params, translator MidiTranslator, wave Waveform, effects ...Effect) SoundGen {
+	dcBlockers := [2]*DCBlocker{NewDCBlocker(ac), NewDCBlocker(ac)} // left and right
+	compressor := NewCompressor(ac, params.CompThreshold, params.CompRatio, params.CompAttack, params.CompRelease, params.CompMakeup)
+	limiter := NewLimiter(ac, params.LimiterThreshold)
+	gate := NewGate(ac, params.GateThreshold)
+	widener := NewWidener(ac, params.Width, params.Haas)
+	mixGain := 1.0
+	headroomGlide := 1 - math.Exp(-1/(float64(ac.SampleRate)*0.02)) // ~20ms for the headroom to follow the voices
+	tremolo := LFO{}
+	pwm := LFO{}
+	nyquist := float64(ac.SampleRate) / 2
+	return func(buf []byte) (int, error) {
+		bytesRead := 0
+		bytesPerSample := ac.BitDepthInBytes * ac.NumChannels
+		numSamples := len(buf) / bytesPerSample
+		deltaT := float64(1) / float64(ac.SampleRate)
+		for sampleIdx := 0; sampleIdx < numSamples; sampleIdx++ {
+			voices := translator()
+
+			pwm.Rate, pwm.Depth = params.PWMRate, params.PWMDepth
+			sweep := pwm.Depth * (2*pwm.Advance(deltaT) - 1)
+			width := clamp(params.PulseWidth+sweep, minPulseWidth, maxPulseWidth)
+			shape := func(phase, freq float64) float64 {
+				if len(params.Harmonics) > 0 {
+					return params.Harmonics.oscillate(phase, freq, nyquist)
+				}
+				if params.Wavetable != nil {
+					return params.Wavetable.oscillate(phase)
+				}
+				step := 0.0
+				if params.BandLimit {
+					step = freq * deltaT
+				}
+				return wave.oscillate(phase, width, step)
+			}
+			// synced plays an oscillator at cycles of the note, reset by the master with hard sync
+			synced := func(cycles, modulation, freq float64) float64 {
+				if ratio := params.SyncRatio; ratio > 0 {
+					return shape(wrap(cycles)*ratio+modulation, freq*ratio)
+				}
+				return shape(cycles+modulation, freq)
+			}
+
+			mix := 0.0
+			side := 0.0 // how much louder the left is than the middle, when voices are panned apart
+			for _, v := range voices {
+				if v.oneShot != nil { // leaving out the oscillators, filter and envelope
+					mix += v.nextOneShot() * v.Velocity
+					continue
+				}
+				var osc float64
+				switch {
+				case wave == WhiteNoise:
+					osc = v.noise.white()
+				case wave == PinkNoise:
+					osc = v.noise.pink()
+				default:
+					modulation := 0.0
+					if params.FM { // the modulator pushes the carrier's phase around, both following the note
+						modulation = params.FMIndex * sine(v.fm.next(v.Freq*params.FMRatio, deltaT)) / (2 * math.Pi)
+					}
+					if n := len(v.unison); n > 1 { // copies spread evenly either side of the note
+						for i := range v.unison {
+							cents := params.UnisonSpread * (float64(i)/float64(n-1) - 0.5)
+							freq := v.Freq * math.Pow(2, cents/1200)
+							osc += synced(v.unison[i].next(freq, deltaT), modulation, freq)
+						}
+						osc /= float64(n)
+					} else {
+						osc = synced(v.osc.next(v.Freq, deltaT), modulation, v.Freq)
+					}
+				}
+				if params.Detune != 0 && !wave.isNoise() {
+					detunedFreq := v.Freq * math.Pow(2, params.Detune/1200)
+					osc = (osc + synced(v.detuned.next(detunedFreq, deltaT), 0, detunedFreq)) / 2
+				}
+				if params.Sub > 0 { // scaled back down so the sub adds weight, not clipping
+					step := 0.0
+					if params.BandLimit {
+						step = v.Freq / 2 * deltaT
+					}
+					sub := params.SubWave.oscillate(v.sub.next(v.Freq/2, deltaT), 0.5, step)
+					osc = (osc + params.Sub*sub) / (1 + params.Sub)
+				}
+				if ringFreq := params.RingMod; ringFreq > 0 || params.RingRatio > 0 {
+					if params.RingRatio > 0 {
+						ringFreq = v.Freq * params.RingRatio // key tracked
+					}
+					osc *= sine(v.ring.next(ringFreq, deltaT))
+				}
+
+				if v.filter == nil {
+					v.filter = NewFilter(ac, params.Cutoff, params.Resonance)
+				}
+				sweep := 1.0 // how far the cutoff is moved from where it's set
+				filterEnv := v.filterEnv.Advance(deltaT, v.Gate)
+				if params.FilterEnvAmount != 0 {
+					sweep *= math.Pow(2, params.FilterEnvAmount*filterEnv)
+				}
+				if params.KeyTrack != 0 && v.Freq > 0 { // higher notes, a higher cutoff, so the timbre holds across the keyboard
+					sweep *= math.Pow(v.Freq/keyTrackFreq, params.KeyTrack)
+				}
+				if params.VelocityCutoff != 0 { // harder playing, brighter notes
+					sweep *= math.Pow(2, params.VelocityCutoff*v.Velocity)
+				}
+				if params.Aftertouch == AftertouchCutoff {
+					sweep *= math.Pow(2, aftertouchOctaves*v.Pressure)
+				}
+				v.filter.Cutoff, v.filter.Resonance = params.Cutoff*sweep, params.Resonance
+				if params.FilterRouting == FilterSingle {
+					osc = v.filter.Process(osc)
+				} else {
+					if v.highPass == nil {
+						v.highPass = NewFilter(ac, params.HPCutoff, params.HPResonance)
+						v.highPass.HighPass = true
+					}
+					// the envelope, velocity and aftertouch move both cutoffs, sweeping a band-pass whole
+					v.highPass.Cutoff, v.highPass.Resonance = params.HPCutoff*sweep, params.HPResonance
+					if params.FilterRouting == FilterSeries {
+						osc = v.highPass.Process(v.filter.Process(osc))
+					} else {
+						osc = v.filter.Process(osc) + v.highPass.Process(osc)
+					}
+				}
+
+				amp := v.env.Advance(deltaT, v.Gate) * v.Velocity * v.fadeGain(deltaT) * v.analogGain(params.Analog)
+				if params.Aftertouch == AftertouchAmplitude {
+					amp *= 1 + aftertouchBoost*v.Pressure
+				}
+				if v.Pan != 0 && ac.NumChannels == 2 {
+					left, right := voicePanGains(v.Pan)
+					mix += osc * amp * (left + right) / 2
+					side += osc * amp * (left - right) / 2
+				} else {
+					mix += osc * amp
+				}
+			}
+
+			// leave headroom as voices pile up, gliding so chords don't duck abruptly
+			target := 1 / math.Sqrt(math.Max(1, float64(len(voices))))
+			mixGain += (target - mixGain) * headroomGlide
+
+			sample := mix * mixGain
+			side *= mixGain
+
+			tremolo.Rate, tremolo.Depth = params.TremoloRate, params.TremoloDepth
+			swell := 1 - tremolo.Depth + tremolo.Depth*tremolo.Advance(deltaT)
+			sample *= swell
+			side *= swell
+
+			// the effects are mono, they process the middle and the voices' spread is added back after
+			for _, effect := range effects {
+				sample = effect.Process(sample)
+			}
+			channels := [2]float64{sample + side, sample - side}
+			if ac.NumChannels == 2 && (params.Width != 1 || params.Haas > 0) {
+				widener.Width, widener.Haas = params.Width, params.Haas
+				channels[0], channels[1] = widener.ProcessStereo(channels[0], channels[1])
+			}
+			for i := range channels {
+				channels[i] = softClip(channels[i], params.Drive)
+				if params.DCBlock {
+					channels[i] = dcBlockers[i].Process(channels[i])
+				}
+				channels[i] *= params.Volume // master fader
+			}
+			if params.CompRatio > 1 {
+				compressor.Threshold, compressor.Ratio, compressor.Makeup = params.CompThreshold, params.CompRatio, params.CompMakeup
+				channels[0], channels[1] = compressor.ProcessStereo(channels[0], channels[1])
+			}
+			if params.Limiter {
+				limiter.Threshold = params.LimiterThreshold
+				channels[0], channels[1] = limiter.ProcessStereo(channels[0], channels[1])
+			}
+			if !math.IsInf(params.GateThreshold, -1) {
+				gate.Threshold = params.GateThreshold
+				channels[0], channels[1] = gate.ProcessStereo(channels[0], channels[1])
+			}
+
+			left, right := panGains(params.Pan)
+			for channelIdx := 0; channelIdx < ac.NumChannels; channelIdx++ {
+				sample, gain := channels[0], 1.0 // mono has nowhere to pan to
+				if ac.NumChannels == 2 {
+					sample, gain = channels[channelIdx], left
+					if channelIdx == 1 {
+						gain = right
+					}
+				}
+				idx := (bytesPerSample * sampleIdx) + (channelIdx * ac.BitDepthInBytes)
+				ac.putSample(buf[idx:], sample*gain)
+				bytesRead = idx + ac.BitDepthInBytes
+			}
+		}
+		return bytesRead, nil
+	}
+}
+
+// panGains are the constant power gains for a pan from -1 (left) to 1 (right)
+func panGains(pan float64) (float64, float64) {
+	angle := (clamp(pan, -1, 1) + 1) * math.Pi / 4
+	return math.Cos(angle), math.Sin(angle)
+}
+
+// toInt16 converts a sample to 16 bit PCM, saturating rather than wrapping
+func toInt16(sample float64) int16 {
+	return int16(clamp(sample, -1, 1) * (math.MaxInt16 - 1))
+}
@@ -0,0 +1,204 @@
+package synth
+
+import (
+	"math"
+	"testing"
+)
+
+var testContext = &AudioContext{SampleRate: 48000, NumChannels: 1, BitDepthInBytes: 2}
+
+// scripted hands out one batch of events each time it's polled, then nothing
+func scripted(batches ...[]Event) MidiHandler {
+	return func() []Event {
+		if len(batches) == 0 {
+			return nil
+		}
+		events := batches[0]
+		batches = batches[1:]
+		return events
+	}
+}
+
+// timeline hands out each batch of events on the poll it's keyed by, counting from 0
+func timeline(at map[int][]Event) MidiHandler {
+	polls := 0
+	return func() []Event {
+		polls++
+		return at[polls-1]
+	}
+}
+
+// poll runs the translator on through its next poll of the handler, returning the voices then
+func poll(translator MidiTranslator) []*Voice {
+	var voices []*Voice
+	for i := 0; i < pollFrames(testContext); i++ {
+		voices = translator()
+	}
+	return voices
+}
+
+func TestNoteOnAtZeroVelocity(t *testing.T) {
+	for _, tt := range []struct{ channel, status int64 }{{0, 0x90}, {3, 0x92}} {
+		channel, status := tt.channel, tt.status
+		params := DefaultParams()
+		params.Channel = channel
+		pool := NewVoicePool(4, Envelope{Attack: 0.01, Decay: 0.1, Sustain: 0.5, Release: 0.1})
+		translator := MakeMidiTranslator(testContext, params, scripted(
+			[]Event{{Status: status, Data1: 60, Data2: 100}},
+			[]Event{{Status: status, Data1: 60, Data2: 0}},
+		), pool)
+		voices := poll(translator)
+		if len(voices) != 1 || voices[0].Note != 60 || !voices[0].Gate {
+			t.Fatalf("channel %d: NOTE ON didn't start note 60", channel)
+		}
+		v := voices[0]
+		poll(translator)
+		if v.Gate {
+			t.Errorf("channel %d: NOTE ON at velocity 0 left the gate open", channel)
+		}
+	}
+}
+
+func TestExtremeNotes(t *testing.T) {
+	tests := []struct {
+		note, transpose int64
+		want            int64 // the note played, -1 for none
+	}{
+		{127, 12, -1},
+		{127, 0, 127},
+		{0, -12, -1},
+		{0, 0, 0},
+		{115, 12, 127},
+		{12, -12, 0},
+		{60, 1 << 40, -1},
+		{60, -1 << 40, -1},
+	}
+	for _, tt := range tests {
+		params := DefaultParams()
+		params.Transpose = tt.transpose
+		pool := NewVoicePool(4, Envelope{Attack: 0.01, Decay: 0.1, Sustain: 0.5, Release: 0.1})
+		translator := MakeMidiTranslator(testContext, params, scripted(
+			[]Event{{Status: 0x90, Data1: tt.note, Data2: 100}},
+			[]Event{{Status: 0xA0, Data1: tt.note, Data2: 100}, {Status: 0x80, Data1: tt.note}},
+		), pool)
+		voices := poll(translator)
+		if tt.want < 0 {
+			if len(voices) != 0 {
+				t.Errorf("note %d transposed %d: played note %d, want it ignored", tt.note, tt.transpose, voices[0].Note)
+			}
+		} else if len(voices) != 1 || voices[0].Note != tt.want {
+			t.Errorf("note %d transposed %d: want note %d", tt.note, tt.transpose, tt.want)
+		} else if f := voices[0].Freq; f < NOTE_MAP[0] || f > NOTE_MAP[127] {
+			t.Errorf("note %d transposed %d: frequency %g outside the midi range", tt.note, tt.transpose, f)
+		}
+		poll(translator) // aftertouch and NOTE OFF for the same note mustn't panic either
+	}
+}
+
+func TestNoteFreqBounds(t *testing.T) {
+	pool := NewVoicePool(1, Envelope{})
+	for _, note := range []int64{-1, -1 << 40, 128, 1 << 40} {
+		if _, ok := pool.noteFreq(note); ok {
+			t.Errorf("noteFreq(%d) reported a frequency off the end of NOTE_MAP", note)
+		}
+	}
+	for _, note := range []int64{0, 127} {
+		if f, ok := pool.noteFreq(note); !ok || f != NOTE_MAP[note] {
+			t.Errorf("noteFreq(%d) = %g, %t, want %g", note, f, ok, NOTE_MAP[note])
+		}
+	}
+	pool.SetNoteMap(BuildNoteMap(432)[:100])
+	if f, ok := pool.noteFreq(69); !ok || f != 432 {
+		t.Errorf("noteFreq(69) = %g, %t tuned to 432, want 432", f, ok)
+	}
+	if _, ok := pool.noteFreq(100); ok {
+		t.Errorf("noteFreq(100) reported a frequency off the end of a shorter map")
+	}
+}
+
+func TestPoolsTunedApart(t *testing.T) {
+	low, high := NewVoicePool(1, Envelope{}), NewVoicePool(1, Envelope{})
+	high.SetNoteMap(BuildNoteMap(880))
+	a4 := []Event{{Status: 0x90, Data1: 69, Data2: 100}}
+	lowVoice := poll(MakeMidiTranslator(testContext, DefaultParams(), scripted(a4), low))[0]
+	highVoice := poll(MakeMidiTranslator(testContext, DefaultParams(), scripted(a4), high))[0]
+	if lowVoice.Freq != 440 || highVoice.Freq != 880 {
+		t.Errorf("A4 played at %gHz and %gHz, want 440 and 880", lowVoice.Freq, highVoice.Freq)
+	}
+}
+
+func TestPollRate(t *testing.T) {
+	polls := 0
+	handler := func() []Event {
+		polls++
+		return nil
+	}
+	translator := MakeMidiTranslator(testContext, DefaultParams(), handler, NewVoicePool(1, Envelope{}))
+	for i := 0; i < testContext.SampleRate; i++ {
+		translator()
+	}
+	if polls != pollsPerSecond {
+		t.Errorf("polled the handler %d times in a second, want %d", polls, pollsPerSecond)
+	}
+}
+
+func TestPanGlides(t *testing.T) {
+	params := DefaultParams()
+	params.CCSlew = 0.01
+	translator := MakeMidiTranslator(testContext, params, scripted(
+		[]Event{{Status: 0xB0, Data1: 10, Data2: 64}},
+		[]Event{{Status: 0xB0, Data1: 10, Data2: 127}},
+	), NewVoicePool(1, Envelope{}))
+	poll(translator)
+	if params.Pan != 0 {
+		t.Fatalf("CC10 at 64 panned to %g, want the centre", params.Pan)
+	}
+	poll(translator)
+	if params.Pan <= 0 || params.Pan >= 0.5 {
+		t.Errorf("CC10 at 127 panned to %g a poll later, want it gliding right", params.Pan)
+	}
+	for i := 0; i < 200; i++ {
+		poll(translator)
+	}
+	if math.Abs(params.Pan-1) > 1e-3 {
+		t.Errorf("CC10 at 127 panned to %g in the end, want hard right", params.Pan)
+	}
+}
+
+func BenchmarkOscGen(b *testing.B) {
+	chord := []Event{{Status: 0x90, Data1: 60, Data2: 100}, {Status: 0x90, Data1: 64, Data2: 100}, {Status: 0x90, Data1: 67, Data2: 100}, {Status: 0x90, Data1: 71, Data2: 100}}
+	params := DefaultParams()
+	params.Cutoff = 2000
+	pool := NewVoicePool(4, Envelope{Attack: 0.01, Decay: 0.1, Sustain: 0.5, Release: 0.1})
+	gen := MakeOscGen(testContext, params, MakeMidiTranslator(testContext, params, scripted(chord), pool), Sawtooth)
+	buf := make([]byte, 512*testContext.NumChannels*testContext.BitDepthInBytes)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		gen(buf)
+	}
+}
+
+// floatContext renders to floats, so tests can read the samples back unrounded
+var floatContext = &AudioContext{SampleRate: 48000, NumChannels: 1, BitDepthInBytes: 4, Format: SampleFloat}
+
+// render plays the timeline through a generator for seconds, returning the samples
+func render(params *Params, pool *VoicePool, wave Waveform, seconds float64, at map[int][]Event) []float64 {
+	gen := MakeOscGen(floatContext, params, MakeMidiTranslator(floatContext, params, timeline(at), pool), wave)
+	buf := make([]byte, int(seconds*float64(floatContext.SampleRate))*floatContext.BitDepthInBytes)
+	gen(buf)
+	samples := make([]float64, len(buf)/floatContext.BitDepthInBytes)
+	for i := range samples {
+		samples[i] = floatContext.sampleAt(buf[i*floatContext.BitDepthInBytes:])
+	}
+	return samples
+}
+
+// maxStep is the largest jump between neighbouring samples
+func maxStep(samples []float64) float64 {
+	worst := 0.0
+	for i := 1; i < len(samples); i++ {
+		worst = math.Max(worst, math.Abs(samples[i]-samples[i-1]))
+	}
+	return worst
+}
@@ -0,0 +1,36 @@
+package synth
+
+import "math"
+
+const (
+	toneLevel     = 0.25 // -12 dBFS, loud enough to hear and well clear of clipping
+	toneSweepTime = 10.0 // seconds a sweep takes to get from one end to the other
+)
+
+// MakeToneGen plays a sine at freq Hz through the oscillator with its gate held open, sweeping up to sweep Hz and back if above 0
+func MakeToneGen(ac *AudioContext, freq, sweep float64, effects ...Effect) SoundGen {
+	params := DefaultParams()
+	params.Volume = toneLevel
+	if ac.NumChannels == 2 {
+		params.Volume *= math.Sqrt2 // the centre pan's 3dB down each side
+	}
+	params.Cutoff = float64(ac.SampleRate) // clamped to just under nyquist, out of the way
+	params.DCBlock, params.Limiter = false, false
+	return MakeOscGen(ac, params, makeToneTranslator(ac, freq, sweep), Sine, effects...)
+}
+
+// makeToneTranslator holds a single voice's gate open at the tone's frequency, in place of midi
+func makeToneTranslator(ac *AudioContext, freq, sweep float64) MidiTranslator {
+	deltaT := 1 / float64(ac.SampleRate)
+	elapsed := 0.0
+	voices := []*Voice{{Freq: freq, Velocity: 1, Gate: true, env: Envelope{Sustain: 1}}}
+	return func() []*Voice {
+		if sweep > 0 {
+			// a triangle from 0 up to 1 and back, across toneSweepTime each way
+			along := math.Abs(math.Mod(elapsed/toneSweepTime+1, 2) - 1)
+			voices[0].Freq = freq * math.Pow(sweep/freq, along)
+		}
+		elapsed += deltaT
+		return voices
+	}
+}
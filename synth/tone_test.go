@@ -0,0 +1,39 @@
+package synth
+
+import (
+	"math"
+	"testing"
+)
+
+func TestToneLevel(t *testing.T) {
+	for _, channels := range []int{1, 2} {
+		ac := &AudioContext{SampleRate: 48000, NumChannels: channels, BitDepthInBytes: 4, Format: SampleFloat}
+		gen := MakeToneGen(ac, 1000, 0)
+		buf := make([]byte, ac.SampleRate*channels*ac.BitDepthInBytes)
+		gen(buf)
+		for ch := 0; ch < channels; ch++ {
+			samples := make([]float64, ac.SampleRate)
+			for i := range samples {
+				samples[i] = ac.sampleAt(buf[(i*channels+ch)*ac.BitDepthInBytes:])
+			}
+			if got := levelToDB(level(samples, 1000, float64(ac.SampleRate))); math.Abs(got+12) > 0.1 {
+				t.Errorf("%d channels: channel %d at %.2f dBFS, want -12", channels, ch, got)
+			}
+			if stray := level(samples, 2000, float64(ac.SampleRate)); stray > 1e-4 {
+				t.Errorf("%d channels: channel %d has a second harmonic at %g", channels, ch, stray)
+			}
+		}
+	}
+}
+
+func TestToneSweep(t *testing.T) {
+	ac := &AudioContext{SampleRate: 1000, NumChannels: 1, BitDepthInBytes: 2}
+	translator := makeToneTranslator(ac, 100, 400)
+	freqs := map[int]float64{0: 100, 5000: 200, 10000: 400, 15000: 200, 20000: 100}
+	for i := 0; i <= 20000; i++ {
+		v := translator()[0]
+		if want, ok := freqs[i]; ok && math.Abs(v.Freq-want) > 0.01 {
+			t.Errorf("%gs into the sweep at %.2fHz, want %g", float64(i)/1000, v.Freq, want)
+		}
+	}
+}
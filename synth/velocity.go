@@ -0,0 +1,52 @@
+package synth
+
+import (
+	"fmt"
+	"math"
+)
+
+// VelocityCurve maps how hard a key is struck onto how loud its note plays
+type VelocityCurve int
+
+const (
+	Linear      VelocityCurve = iota
+	Exponential               // soft playing is quieter, hard playing punchier
+	Logarithmic               // soft playing comes through louder
+	Fixed                     // every note at full velocity, organ style
+)
+
+var velocityCurveNames = map[VelocityCurve]string{
+	Linear:      "linear",
+	Exponential: "exponential",
+	Logarithmic: "logarithmic",
+	Fixed:       "fixed",
+}
+
+func (c VelocityCurve) String() string {
+	return velocityCurveNames[c]
+}
+
+// ParseVelocityCurve looks up a curve by its name: linear, exponential, logarithmic or fixed
+func ParseVelocityCurve(name string) (VelocityCurve, error) {
+	for c, n := range velocityCurveNames {
+		if n == name {
+			return c, nil
+		}
+	}
+	return Linear, fmt.Errorf("unknown velocity curve %q", name)
+}
+
+// velocity maps a midi velocity, 0 to 127, through the curve into 0 to 1
+func (c VelocityCurve) velocity(data2 int64) float64 {
+	linear := float64(data2) / 128.0
+	switch c {
+	case Exponential:
+		return linear * linear
+	case Logarithmic:
+		return math.Log10(1 + 9*linear)
+	case Fixed:
+		return 1
+	default:
+		return linear
+	}
+}
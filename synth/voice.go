@@ -0,0 +1,465 @@
+package synth
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Voice is a single sounding note, carrying its own oscillator position and envelope
+type Voice struct {
+	Note     int64
+	Freq     float64
+	Velocity float64
+	Gate     bool
+	Pressure float64 // aftertouch, 0 to 1, from the key itself or the whole channel
+	Pan      float64 // the voice's own place in the stereo image, -1 to 1, by the pool's VoicePan
+
+	sustained    bool    // key released while the pedal is down, waiting for the pedal to lift
+	sostenuto    bool    // sounding when the sostenuto pedal went down, so held until it lifts
+	notePressure float64 // the key's own polyphonic aftertouch
+	baseFreq     float64 // the note's frequency before bend and vibrato, partway through any glide
+	glideFrom    float64 // frequency the previous note had reached when this one began
+	glideElapsed float64 // seconds into the glide
+	legato       bool    // another key was down when this note began
+	osc          phase
+	detuned      phase   // the second oscillator, when detuned
+	unison       []phase // the detuned copies playing the note in place of osc, when there's more than one
+	sub          phase   // the sub oscillator, an octave down
+	ring         phase   // the ring modulator's sine
+	fm           phase   // the FM modulator
+	noise        noise
+	filter       *Filter // made for the context's sample rate on the voice's first sample
+	highPass     *Filter // likewise, for the routings with a second filter
+	env          Envelope
+	filterEnv    Envelope // sweeping the filter's cutoff, on the same gate
+	fade         float64  // gain left as a stolen voice fades out under the new note, 0 when it isn't
+	fadeStep     float64  // how much the fade falls a second
+	oneShot      OneShot  // the recording the voice plays in place of its oscillators, for a sample mapped note
+	oneShotPos   int      // samples of it played
+	pitchDrift   drift    // random walks the analog amount wanders the pitch and level by
+	levelDrift   drift
+}
+
+// VoicePool hands out voices to incoming notes, up to a maximum number sounding at once
+type VoicePool struct {
+	voices         []*Voice  // in allocation order, oldest first
+	held           []heldKey // keys down, in the order they were pressed
+	max            int
+	envelope       Envelope   // copied into every new voice
+	filterEnvelope Envelope   // likewise
+	sustain        bool       // sustain pedal is down
+	sostenuto      bool       // sostenuto pedal is down
+	rng            *rand.Rand // shared by the voices' noise and drift, the random arpeggio and the unison phases
+	notes          []float64  // the frequency of every midi note, NOTE_MAP unless SetNoteMap is given another
+	last           *Voice     // most recently started, where the next note glides from
+
+	priority  NotePriority // which keys keep a voice when there are more than voices
+	retrigger bool         // restart the envelope when a note takes over a voice still sounding
+	fingered  bool         // only carry on legato for notes played while another key is down
+	unison    int          // oscillators each voice plays its note with
+	stealFade float64      // seconds a stolen voice fades out over, 0 to hand it straight over
+	velEnv    float64      // how much velocity shortens or lengthens the envelope, by SetVelocityEnvelope
+	velDecay  bool         // velEnv scales the decay too, not only the attack
+	fading    []*Voice     // stolen voices fading out
+	playing   []*Voice     // the voices, those fading and the one-shots, as Voices returns them
+	oneShots  []*Voice     // one-shots still playing, by Trigger
+	pan       VoicePan     // where new voices are placed in the stereo image
+	started   int          // voices started so far, counting for round robin panning
+}
+
+// heldKey is a key that's down, whether or not its note still has a voice
+type heldKey struct {
+	note     int64
+	velocity float64
+}
+
+// NewVoicePool makes a pool of up to max voices, each starting with a copy of env
+func NewVoicePool(max int, env Envelope) *VoicePool {
+	if max < 1 {
+		max = 1
+	}
+	return &VoicePool{
+		voices:   make([]*Voice, 0, max),
+		max:      max,
+		envelope: env,
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Seed reseeds everything random the pool does, so a render can be reproduced
+func (vp *VoicePool) Seed(seed int64) {
+	vp.rng.Seed(seed)
+}
+
+// SetEnvelope changes the envelope of new notes and those already sounding
+func (vp *VoicePool) SetEnvelope(env Envelope) {
+	vp.envelope = env
+	for _, v := range vp.voices {
+		v.env.Attack, v.env.Decay, v.env.Sustain, v.env.Release = env.Attack, env.Decay, env.Sustain, env.Release
+	}
+}
+
+// SetNoteMap tunes the pool's notes, a frequency in Hz for each midi note
+func (vp *VoicePool) SetNoteMap(notes []float64) {
+	vp.notes = notes
+}
+
+// SetFilterEnvelope sets the envelope that sweeps each new note's filter cutoff
+func (vp *VoicePool) SetFilterEnvelope(env Envelope) {
+	vp.filterEnvelope = env
+}
+
+// SetPriority chooses which keys sound when more are held than there are voices
+func (vp *VoicePool) SetPriority(priority NotePriority) {
+	vp.priority = priority
+}
+
+// SetRetrigger chooses whether a note taking over a sounding voice restarts its envelope
+func (vp *VoicePool) SetRetrigger(on bool) {
+	vp.retrigger = on
+}
+
+// SetVelocityEnvelope makes harder notes attack faster by amount, and their decays too with decay
+func (vp *VoicePool) SetVelocityEnvelope(amount float64, decay bool) {
+	vp.velEnv, vp.velDecay = amount, decay
+}
+
+// SetFingeredLegato makes only notes played while another key is down take over a voice legato
+func (vp *VoicePool) SetFingeredLegato(on bool) {
+	vp.fingered = on
+}
+
+// SetUnison gives each new note count oscillators to spread in pitch
+func (vp *VoicePool) SetUnison(count int) {
+	vp.unison = count
+}
+
+// SetVoicePan chooses how new voices are spread across the stereo image
+func (vp *VoicePool) SetVoicePan(pan VoicePan) {
+	vp.pan = pan
+}
+
+// SetStealFade makes a stolen voice fade out over seconds while the new note starts afresh
+func (vp *VoicePool) SetStealFade(seconds float64) {
+	vp.stealFade = seconds
+}
+
+// NoteOn starts a voice for the note, retriggering it if it's still ringing or stealing one by priority
+func (vp *VoicePool) NoteOn(note int64, velocity float64) {
+	if _, ok := vp.noteFreq(note); !ok {
+		return
+	}
+	vp.held = append(vp.held, heldKey{note: note, velocity: velocity})
+	if vp.waits(note) {
+		return
+	}
+	vp.start(note, velocity)
+}
+
+func (vp *VoicePool) start(note int64, velocity float64) {
+	glideFrom := 0.0
+	if vp.last != nil {
+		glideFrom = vp.last.baseFreq
+	}
+	v := vp.find(note)
+	restruck := v != nil && v.Gate // held by the pedal or another press, so it attacks again
+	if v == nil {
+		if len(vp.voices) < vp.max {
+			v = vp.newVoice()
+		} else if v = vp.steal(); vp.stealFade > 0 && vp.max > 1 {
+			vp.fadeOut(v)
+			v = vp.newVoice()
+		}
+		v.Note = note
+		v.sostenuto = false // a stolen voice doesn't keep the pedal's hold
+		v.Pan = vp.pan.position(note, vp.started, vp.max)
+		vp.started++
+	} else {
+		vp.remove(v)
+	}
+	legato := len(vp.held) > 1
+	if v.Gate && (restruck || vp.retrigger || (vp.fingered && !legato)) {
+		v.env.retrigger()
+		v.filterEnv.retrigger()
+	}
+	if freq, _ := vp.noteFreq(note); vp.unison > 1 && len(v.unison) != vp.unison {
+		v.unison = vp.unisonPhases(freq)
+	}
+	v.Velocity = velocity
+	if vp.velEnv != 0 {
+		v.env.shorten, v.env.shortenDecay = math.Min(1, vp.velEnv*(2*velocity-1)), vp.velDecay
+	}
+	v.Gate = true
+	v.sustained = false
+	v.notePressure = 0
+	v.glideFrom, v.glideElapsed = glideFrom, 0
+	v.legato = legato
+	vp.voices = append(vp.voices, v)
+	vp.last = v
+}
+
+func (vp *VoicePool) newVoice() *Voice {
+	return &Voice{env: vp.envelope, filterEnv: vp.filterEnvelope, noise: noise{rng: vp.rng}}
+}
+
+// fadeOut sets a stolen voice fading, sounding on until it's silent
+func (vp *VoicePool) fadeOut(v *Voice) {
+	v.fade, v.fadeStep = 1, 1/vp.stealFade
+	vp.fading = append(vp.fading, v)
+}
+
+// fadeGain is the voice's gain as it fades out after being stolen, moving the fade on by deltaT
+func (v *Voice) fadeGain(deltaT float64) float64 {
+	if v.fadeStep == 0 {
+		return 1
+	}
+	gain := v.fade
+	v.fade -= v.fadeStep * deltaT
+	return math.Max(0, gain)
+}
+
+// unisonPhases starts each unison oscillator at a random point so they don't cancel
+func (vp *VoicePool) unisonPhases(freq float64) []phase {
+	phases := make([]phase, vp.unison)
+	for i := range phases {
+		phases[i] = phase{pos: vp.rng.Float64() / freq, lastFreq: freq}
+	}
+	return phases
+}
+
+// NoteOff releases the note once every press of it is up and no pedal holds it, handing back a stolen voice
+func (vp *VoicePool) NoteOff(note int64) {
+	if !vp.release(note) {
+		return // a NOTE OFF for a key that isn't down
+	}
+	if vp.isHeld(note) {
+		return // still held by another press
+	}
+	for _, v := range vp.voices {
+		if v.Note == note && v.Gate && !v.sostenuto {
+			if vp.sustain {
+				v.sustained = true
+			} else {
+				v.Gate = false
+			}
+		}
+	}
+	if !vp.sustain {
+		vp.resume()
+	}
+}
+
+// SetSustain presses or lifts the sustain pedal, lifting it releases every note held only by the pedal
+func (vp *VoicePool) SetSustain(on bool) {
+	vp.sustain = on
+	if on {
+		return
+	}
+	for _, v := range vp.voices {
+		if v.sustained {
+			v.sustained = false
+			v.Gate = v.sostenuto // still held by the other pedal
+		}
+	}
+	vp.resume()
+}
+
+// SetSostenuto presses or lifts the sostenuto pedal, holding only the notes sounding as it goes down
+func (vp *VoicePool) SetSostenuto(on bool) {
+	if on == vp.sostenuto {
+		return
+	}
+	vp.sostenuto = on
+	for _, v := range vp.voices {
+		if on {
+			v.sostenuto = v.Gate
+			continue
+		}
+		if !v.sostenuto {
+			continue
+		}
+		v.sostenuto = false
+		if !v.Gate || vp.isHeld(v.Note) {
+			continue
+		}
+		if vp.sustain {
+			v.sustained = true
+		} else {
+			v.Gate = false
+		}
+	}
+	if !on && !vp.sustain {
+		vp.resume()
+	}
+}
+
+// Voices drops voices and one-shots that have finished and returns the rest
+func (vp *VoicePool) Voices() []*Voice {
+	active := vp.voices[:0]
+	for _, v := range vp.voices {
+		if v.Gate || !v.env.Idle() {
+			active = append(active, v)
+		}
+	}
+	for i := len(active); i < len(vp.voices); i++ {
+		vp.voices[i] = nil
+	}
+	vp.voices = active
+	if len(vp.fading) == 0 && len(vp.oneShots) == 0 {
+		return vp.voices
+	}
+	fading := vp.fading[:0]
+	for _, v := range vp.fading {
+		if v.fade > 0 && !v.env.Idle() {
+			fading = append(fading, v)
+		}
+	}
+	for i := len(fading); i < len(vp.fading); i++ {
+		vp.fading[i] = nil
+	}
+	vp.fading = fading
+	oneShots := vp.oneShots[:0]
+	for _, v := range vp.oneShots {
+		if v.oneShotPos < len(v.oneShot) {
+			oneShots = append(oneShots, v)
+		}
+	}
+	for i := len(oneShots); i < len(vp.oneShots); i++ {
+		vp.oneShots[i] = nil
+	}
+	vp.oneShots = oneShots
+	vp.playing = append(append(append(vp.playing[:0], vp.voices...), vp.fading...), vp.oneShots...)
+	return vp.playing
+}
+
+// AllNotesOff releases every note as if its key and the sustain pedal were let go
+func (vp *VoicePool) AllNotesOff() {
+	vp.held = vp.held[:0]
+	vp.sustain = false
+	vp.sostenuto = false
+	for _, v := range vp.voices {
+		v.Gate = false
+		v.sustained = false
+		v.sostenuto = false
+	}
+}
+
+// AllSoundOff silences everything at once, cutting releases short too
+func (vp *VoicePool) AllSoundOff() {
+	vp.AllNotesOff()
+	for _, v := range vp.voices {
+		v.env.cut()
+	}
+	for _, v := range vp.fading {
+		v.env.cut()
+	}
+	for i := range vp.oneShots {
+		vp.oneShots[i] = nil
+	}
+	vp.oneShots = vp.oneShots[:0]
+}
+
+// SetPressure sets the polyphonic aftertouch of the note's voice
+func (vp *VoicePool) SetPressure(note int64, pressure float64) {
+	if v := vp.find(note); v != nil {
+		v.notePressure = pressure
+	}
+}
+
+// isHeld reports whether the note's key is down
+func (vp *VoicePool) isHeld(note int64) bool {
+	for _, k := range vp.held {
+		if k.note == note {
+			return true
+		}
+	}
+	return false
+}
+
+// release forgets the latest press of the note, reporting whether it was held at all
+func (vp *VoicePool) release(note int64) bool {
+	for i := len(vp.held) - 1; i >= 0; i-- {
+		if vp.held[i].note == note {
+			vp.held = append(vp.held[:i], vp.held[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// resume gives the silent held key next in line its voice back
+func (vp *VoicePool) resume() {
+	var next *heldKey
+	for i := len(vp.held) - 1; i >= 0; i-- {
+		k := &vp.held[i]
+		if v := vp.find(k.note); v != nil && v.Gate {
+			continue
+		}
+		if next == nil || (vp.priority != PriorityLast && vp.priority.beats(k.note, next.note)) {
+			next = k
+		}
+	}
+	if next != nil && !vp.waits(next.note) {
+		vp.start(next.note, next.velocity)
+	}
+}
+
+// waits reports whether every voice is playing a note the priority ranks above this one
+func (vp *VoicePool) waits(note int64) bool {
+	if vp.priority == PriorityLast || vp.find(note) != nil || len(vp.voices) < vp.max {
+		return false
+	}
+	worst := vp.outranked()
+	return worst != nil && !vp.priority.beats(note, worst.Note)
+}
+
+// outranked is the sounding voice the priority puts last, or nil when some voice has been released
+func (vp *VoicePool) outranked() *Voice {
+	var worst *Voice
+	for _, v := range vp.voices {
+		if !v.Gate {
+			return nil
+		}
+		if worst == nil || vp.priority.beats(worst.Note, v.Note) {
+			worst = v
+		}
+	}
+	return worst
+}
+
+func (vp *VoicePool) find(note int64) *Voice {
+	for _, v := range vp.voices {
+		if v.Note == note {
+			return v
+		}
+	}
+	return nil
+}
+
+// steal takes the voice the priority puts last, preferring a released one
+func (vp *VoicePool) steal() *Voice {
+	victim := vp.voices[0]
+	if vp.priority != PriorityLast {
+		if worst := vp.outranked(); worst != nil {
+			victim = worst
+		}
+	}
+	for _, v := range vp.voices {
+		if !v.Gate {
+			victim = v
+			break
+		}
+	}
+	vp.remove(victim)
+	return victim
+}
+
+func (vp *VoicePool) remove(target *Voice) {
+	for i, v := range vp.voices {
+		if v == target {
+			vp.voices = append(vp.voices[:i], vp.voices[i+1:]...)
+			return
+		}
+	}
+}
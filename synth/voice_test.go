@@ -0,0 +1,294 @@
+package synth
+
+import (
+	"math"
+	"testing"
+)
+
+// sounding is the notes the pool has gated on, in allocation order
+func sounding(vp *VoicePool) []int64 {
+	notes := []int64{}
+	for _, v := range vp.Voices() {
+		if v.Gate {
+			notes = append(notes, v.Note)
+		}
+	}
+	return notes
+}
+
+func TestOverlappingNotes(t *testing.T) {
+	const a, b, c = 60, 64, 67
+	tests := []struct {
+		name   string
+		voices int
+		play   func(vp *VoicePool)
+		want   []int64
+	}{
+		{"release the earlier key", 1, func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.NoteOn(b, 1)
+			vp.NoteOff(a)
+		}, []int64{b}},
+		{"release the sounding key", 1, func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.NoteOn(b, 1)
+			vp.NoteOff(b)
+		}, []int64{a}},
+		{"release both", 1, func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.NoteOn(b, 1)
+			vp.NoteOff(a)
+			vp.NoteOff(b)
+		}, []int64{}},
+		{"fall back past a released key", 1, func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.NoteOn(b, 1)
+			vp.NoteOn(c, 1)
+			vp.NoteOff(b)
+			vp.NoteOff(c)
+		}, []int64{a}},
+		{"trill", 1, func(vp *VoicePool) {
+			for i := 0; i < 4; i++ {
+				vp.NoteOn(b, 1)
+				vp.NoteOff(a)
+				vp.NoteOn(a, 1)
+				vp.NoteOff(b)
+			}
+		}, []int64{a}},
+		{"same note twice", 1, func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.NoteOn(a, 1)
+			vp.NoteOff(a)
+		}, []int64{a}},
+		{"stray note off", 1, func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.NoteOff(b)
+		}, []int64{a}},
+		{"poly release the earlier key", 4, func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.NoteOn(b, 1)
+			vp.NoteOff(a)
+		}, []int64{b}},
+	}
+	for _, tt := range tests {
+		vp := NewVoicePool(tt.voices, Envelope{Attack: 0.01, Decay: 0.1, Sustain: 0.5, Release: 0.1})
+		tt.play(vp)
+		got := sounding(vp)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: sounding %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: sounding %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestFallbackKeepsVelocity(t *testing.T) {
+	vp := NewVoicePool(1, Envelope{Attack: 0.01, Decay: 0.1, Sustain: 0.5, Release: 0.1})
+	vp.NoteOn(60, 0.25)
+	vp.NoteOn(64, 1)
+	vp.NoteOff(64)
+	voices := vp.Voices()
+	if len(voices) != 1 || voices[0].Note != 60 || !voices[0].Gate {
+		t.Fatalf("after releasing the second key the voice should fall back to the first")
+	}
+	if voices[0].Velocity != 0.25 {
+		t.Errorf("fell back at velocity %g, want the first key's 0.25", voices[0].Velocity)
+	}
+}
+
+func TestStealFade(t *testing.T) {
+	const stealAt = 100 // polls, a millisecond each
+	notes := map[int][]Event{
+		0:       {{Status: 0x90, Data1: 60, Data2: 127}, {Status: 0x90, Data1: 67, Data2: 127}},
+		stealAt: {{Status: 0x90, Data1: 64, Data2: 127}}, // takes 60's voice
+	}
+	params := DefaultParams()
+	params.Limiter, params.DCBlock = false, false
+	env := Envelope{Attack: 0.005, Decay: 0.1, Sustain: 1, Release: 1}
+	pollSamples := pollFrames(floatContext)
+
+	pool := NewVoicePool(2, env)
+	pool.SetStealFade(0.005)
+	samples := render(params, pool, Sine, 0.2, notes)
+	before := maxStep(samples[(stealAt-20)*pollSamples : stealAt*pollSamples])
+	after := maxStep(samples[stealAt*pollSamples : (stealAt+20)*pollSamples])
+	if after > 1.5*before {
+		t.Errorf("stealing a voice jumped the output by %g, against %g before it", after, before)
+	}
+
+}
+
+func TestSustainAndSostenuto(t *testing.T) {
+	const a, b, c = 60, 64, 67
+	tests := []struct {
+		name string
+		play func(vp *VoicePool)
+		want []int64
+	}{
+		{"sostenuto holds the notes down", func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.SetSostenuto(true)
+			vp.NoteOff(a)
+		}, []int64{a}},
+		{"but not those played after it", func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.SetSostenuto(true)
+			vp.NoteOn(b, 1)
+			vp.NoteOff(a)
+			vp.NoteOff(b)
+		}, []int64{a}},
+		{"lifting it releases them", func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.SetSostenuto(true)
+			vp.NoteOff(a)
+			vp.SetSostenuto(false)
+		}, []int64{}},
+		{"unless their keys are still down", func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.SetSostenuto(true)
+			vp.SetSostenuto(false)
+		}, []int64{a}},
+		{"sustain holds the notes after sostenuto", func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.SetSostenuto(true)
+			vp.SetSustain(true)
+			vp.NoteOn(b, 1)
+			vp.NoteOff(a)
+			vp.NoteOff(b)
+		}, []int64{a, b}},
+		{"lifting sustain leaves the sostenuto notes", func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.SetSostenuto(true)
+			vp.SetSustain(true)
+			vp.NoteOn(b, 1)
+			vp.NoteOff(a)
+			vp.NoteOff(b)
+			vp.SetSustain(false)
+		}, []int64{a}},
+		{"lifting sostenuto leaves the sustained notes", func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.SetSostenuto(true)
+			vp.SetSustain(true)
+			vp.NoteOff(a)
+			vp.SetSostenuto(false)
+		}, []int64{a}},
+		{"until sustain lifts too", func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.SetSostenuto(true)
+			vp.SetSustain(true)
+			vp.NoteOff(a)
+			vp.SetSostenuto(false)
+			vp.SetSustain(false)
+		}, []int64{}},
+		{"sostenuto pressed under sustain holds only the keys down", func(vp *VoicePool) {
+			vp.NoteOn(a, 1)
+			vp.NoteOn(b, 1)
+			vp.SetSustain(true)
+			vp.NoteOff(b) // sustained, so still sounding when sostenuto goes down
+			vp.SetSostenuto(true)
+			vp.NoteOn(c, 1)
+			vp.NoteOff(a)
+			vp.NoteOff(c)
+			vp.SetSustain(false)
+		}, []int64{a, b}},
+	}
+	for _, tt := range tests {
+		vp := NewVoicePool(4, Envelope{Attack: 0.01, Decay: 0.1, Sustain: 0.5, Release: 0.1})
+		tt.play(vp)
+		got := sounding(vp)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: sounding %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: sounding %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestLegatoGlide(t *testing.T) {
+	const from, to = 60, 64
+	tests := []struct {
+		name    string
+		at      map[int][]Event
+		glides  bool // into the second note, rather than jumping to its pitch
+		attacks bool // restarting the envelope for it
+	}{
+		{"overlapping", map[int][]Event{
+			0:  {{Status: 0x90, Data1: from, Data2: 100}},
+			50: {{Status: 0x90, Data1: to, Data2: 100}},
+		}, true, false},
+		{"isolated", map[int][]Event{
+			0:  {{Status: 0x90, Data1: from, Data2: 100}},
+			30: {{Status: 0x80, Data1: from}},
+			50: {{Status: 0x90, Data1: to, Data2: 100}},
+		}, false, true},
+		{"isolated under the sustain pedal", map[int][]Event{
+			0:  {{Status: 0x90, Data1: from, Data2: 100}, {Status: 0xB0, Data1: 64, Data2: 127}},
+			30: {{Status: 0x80, Data1: from}},
+			50: {{Status: 0x90, Data1: to, Data2: 100}},
+		}, false, true},
+	}
+	for _, tt := range tests {
+		params := DefaultParams()
+		params.Glide, params.GlideMode = 100, GlideLegato
+		pool := NewVoicePool(1, Envelope{Attack: 0.005, Decay: 0.005, Sustain: 0.5, Release: 0.5})
+		pool.SetFingeredLegato(true)
+		translator := MakeMidiTranslator(testContext, params, timeline(tt.at), pool)
+		deltaT := 1 / float64(testContext.SampleRate)
+		var v *Voice
+		for i := 0; i < 60*pollFrames(testContext); i++ { // 10ms into the second note
+			for _, v = range translator() {
+				v.env.Advance(deltaT, v.Gate)
+			}
+			if i == 50*pollFrames(testContext) {
+				if attacking := v.env.stage == envAttack; attacking != tt.attacks {
+					t.Errorf("%s: the second note attacking %t, want %t", tt.name, attacking, tt.attacks)
+				}
+			}
+		}
+		if v.Note != to {
+			t.Fatalf("%s: playing note %d, want %d", tt.name, v.Note, to)
+		}
+		gliding := v.Freq > NOTE_MAP[from] && v.Freq < NOTE_MAP[to]
+		if gliding != tt.glides || (!gliding && v.Freq != NOTE_MAP[to]) {
+			t.Errorf("%s: 10ms in at %.2fHz, from %.2fHz to %.2fHz, want gliding %t", tt.name, v.Freq, NOTE_MAP[from], NOTE_MAP[to], tt.glides)
+		}
+	}
+}
+
+func TestRestrikeUnderSustain(t *testing.T) {
+	const deltaT = 1.0 / 48000
+	vp := NewVoicePool(4, Envelope{Attack: 0.001, Decay: 0.05, Sustain: 0, Release: 0.5})
+	advance := func(seconds float64) float64 {
+		peak := 0.0
+		for i := 0; i < int(seconds/deltaT); i++ {
+			for _, v := range vp.Voices() {
+				peak = math.Max(peak, v.env.Advance(deltaT, v.Gate))
+			}
+		}
+		return peak
+	}
+	vp.SetSustain(true)
+	vp.NoteOn(60, 1)
+	advance(0.5) // decayed away to nothing
+	vp.NoteOff(60)
+	vp.NoteOn(60, 1)
+	if peak := advance(0.01); peak < 0.9 {
+		t.Errorf("re-striking a note held by the sustain pedal peaked at %g, want it to attack again", peak)
+	}
+	vp.NoteOn(60, 1) // and again without letting go
+	advance(0.5)
+	vp.NoteOn(60, 1)
+	if peak := advance(0.01); peak < 0.9 {
+		t.Errorf("repeating a held note peaked at %g, want it to attack again", peak)
+	}
+}
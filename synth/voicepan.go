@@ -0,0 +1,65 @@
+package synth
+
+import (
+	"fmt"
+	"math"
+)
+
+// VoicePan decides where each voice sits in the stereo image
+type VoicePan int
+
+const (
+	VoicePanOff        VoicePan = iota // every voice in the middle, following the master pan
+	VoicePanNote                       // by pitch, low notes to the left and high to the right
+	VoicePanRoundRobin                 // each new voice takes the next of a spread of positions
+)
+
+var voicePanNames = map[VoicePan]string{
+	VoicePanOff:        "off",
+	VoicePanNote:       "note",
+	VoicePanRoundRobin: "roundrobin",
+}
+
+func (p VoicePan) String() string {
+	return voicePanNames[p]
+}
+
+// ParseVoicePan looks up a voice panning mode by its name: off, note or roundrobin
+func ParseVoicePan(name string) (VoicePan, error) {
+	for p, n := range voicePanNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return VoicePanOff, fmt.Errorf("unknown voice pan %q", name)
+}
+
+// voicePanWidth keeps the voices short of the hard edges, so none drops out of one speaker
+const voicePanWidth = 0.8
+
+// position is the pan, -1 to 1, for the note started as the count'th voice of a pool of max
+func (p VoicePan) position(note int64, count, max int) float64 {
+	switch p {
+	case VoicePanNote: // C1 to C7 spans the width
+		return voicePanWidth * clamp(float64(note-60)/36, -1, 1)
+	case VoicePanRoundRobin:
+		if max < 2 {
+			return 0
+		}
+		// alternate sides working inwards, so even a couple of notes end up apart
+		i := count % max
+		slot := i / 2
+		if i%2 == 1 {
+			slot = max - 1 - i/2
+		}
+		return voicePanWidth * (2*float64(slot)/float64(max-1) - 1)
+	default:
+		return 0
+	}
+}
+
+// voicePanGains are the left and right gains for a voice at pan, full in both at the middle
+func voicePanGains(pan float64) (float64, float64) {
+	left, right := panGains(pan)
+	return left * math.Sqrt2, right * math.Sqrt2
+}
@@ -0,0 +1,116 @@
+package synth
+
+import (
+	"encoding/binary"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+const wavHeaderSize = 44
+
+// WavWriter records PCM frames to a WAV file, its header sizes patched in by Close
+type WavWriter struct {
+	mu       sync.Mutex
+	f        *os.File
+	dataSize uint32
+	closed   bool
+}
+
+// NewWavWriter creates the file at path and writes a header for audio in the context's format
+func NewWavWriter(path string, ac *AudioContext) (*WavWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeWavHeader(f, ac, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &WavWriter{f: f}, nil
+}
+
+// writeWavHeader writes the RIFF, fmt and data chunk headers for dataSize bytes of PCM
+func writeWavHeader(w io.Writer, ac *AudioContext, dataSize uint32) error {
+	blockAlign := ac.NumChannels * ac.BitDepthInBytes
+	format := uint16(1) // PCM
+	if ac.Format == SampleFloat {
+		format = 3 // IEEE float
+	}
+	header := []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'},
+		uint32(wavHeaderSize - 8 + dataSize),
+		[4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '},
+		uint32(16), // fmt chunk size
+		format,
+		uint16(ac.NumChannels),
+		uint32(ac.SampleRate),
+		uint32(ac.SampleRate * blockAlign), // byte rate
+		uint16(blockAlign),
+		uint16(ac.BitDepthInBytes * 8),
+		[4]byte{'d', 'a', 't', 'a'},
+		dataSize,
+	}
+	for _, field := range header {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write appends PCM frames, anything written after Close is dropped
+func (w *WavWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, nil
+	}
+	n, err := w.f.Write(p)
+	w.dataSize += uint32(n)
+	return n, err
+}
+
+// Close patches the RIFF and data chunk sizes now that the length is known, and closes the file
+func (w *WavWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if _, err := w.f.Seek(4, io.SeekStart); err != nil {
+		w.f.Close()
+		return err
+	}
+	if err := binary.Write(w.f, binary.LittleEndian, uint32(wavHeaderSize-8)+w.dataSize); err != nil {
+		w.f.Close()
+		return err
+	}
+	if _, err := w.f.Seek(wavHeaderSize-4, io.SeekStart); err != nil {
+		w.f.Close()
+		return err
+	}
+	if err := binary.Write(w.f, binary.LittleEndian, w.dataSize); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// MakeRecorder tees the generator into w, a failed write stopping the recording but not the playback
+func MakeRecorder(gen SoundGen, w io.Writer) SoundGen {
+	failed := false
+	return func(buf []byte) (int, error) {
+		n, err := gen(buf)
+		if !failed && n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				log.Printf("Error recording: %s", werr.Error())
+				failed = true
+			}
+		}
+		return n, err
+	}
+}
@@ -0,0 +1,88 @@
+package synth
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWavRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		ac        *AudioContext
+		tolerance float64
+	}{
+		{"16 bit", &AudioContext{SampleRate: 44100, NumChannels: 1, BitDepthInBytes: 2}, 1.0 / (1 << 12)},
+		{"24 bit", &AudioContext{SampleRate: 48000, NumChannels: 1, BitDepthInBytes: 3}, 1.0 / (1 << 20)},
+		{"float", &AudioContext{SampleRate: 96000, NumChannels: 1, BitDepthInBytes: 4, Format: SampleFloat}, 1e-7},
+	}
+	for _, tt := range tests {
+		want := make([]float64, 1000)
+		pcm := make([]byte, len(want)*tt.ac.BitDepthInBytes)
+		for i := range want {
+			want[i] = 0.9 * math.Sin(2*math.Pi*float64(i)/37)
+			tt.ac.putSample(pcm[i*tt.ac.BitDepthInBytes:], want[i])
+		}
+		path := filepath.Join(t.TempDir(), "out.wav")
+		w, err := NewWavWriter(path, tt.ac)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(pcm[:len(pcm)/2]); err != nil { // in two goes, as a recording would be
+			t.Fatal(err)
+		}
+		if _, err := w.Write(pcm[len(pcm)/2:]); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size := binary.LittleEndian.Uint32(data[4:8]); int(size) != len(data)-8 {
+			t.Errorf("%s: RIFF size %d, want %d", tt.name, size, len(data)-8)
+		}
+		got, rate, err := parseWavSamples(data[12:])
+		if err != nil {
+			t.Fatalf("%s: reading it back: %s", tt.name, err.Error())
+		}
+		if rate != tt.ac.SampleRate {
+			t.Errorf("%s: read back at %d Hz, want %d", tt.name, rate, tt.ac.SampleRate)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: read back %d samples, want %d", tt.name, len(got), len(want))
+		}
+		for i := range want {
+			if math.Abs(got[i]-want[i]) > tt.tolerance {
+				t.Errorf("%s: sample %d read back as %g, want %g", tt.name, i, got[i], want[i])
+				break
+			}
+		}
+	}
+}
+
+func TestWavWriteAfterClose(t *testing.T) {
+	ac := &AudioContext{SampleRate: 44100, NumChannels: 2, BitDepthInBytes: 2}
+	path := filepath.Join(t.TempDir(), "out.wav")
+	w, err := NewWavWriter(path, ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(make([]byte, 400))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write(make([]byte, 400))
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != wavHeaderSize+400 {
+		t.Errorf("file is %d bytes after a write past Close, want %d", info.Size(), wavHeaderSize+400)
+	}
+}
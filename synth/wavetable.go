@@ -0,0 +1,136 @@
+package synth
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// wavetableSize is the length every loaded waveform is resampled to
+const wavetableSize = 2048
+
+// Wavetable is a single cycle of a waveform, normalized to peak at full scale
+type Wavetable []float64
+
+// ReadWavetable loads a single cycle from a WAV or raw 16 bit file, resampled and normalized
+func ReadWavetable(path string) (Wavetable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var samples []float64
+	if len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE" {
+		if samples, _, err = parseWavSamples(data[12:]); err != nil {
+			return nil, err
+		}
+	} else {
+		for i := 0; i+1 < len(data); i += 2 {
+			samples = append(samples, float64(int16(binary.LittleEndian.Uint16(data[i:])))/math.MaxInt16)
+		}
+	}
+	if len(samples) < 2 {
+		return nil, errors.New("the waveform needs at least 2 samples")
+	}
+	return newWavetable(samples), nil
+}
+
+// parseWavSamples reads a WAV file's first channel and its sample rate
+func parseWavSamples(data []byte) ([]float64, int, error) {
+	var format struct {
+		Format        uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+	}
+	haveFormat := false
+	for len(data) >= 8 {
+		id, size := string(data[0:4]), int(binary.LittleEndian.Uint32(data[4:8]))
+		data = data[8:]
+		if size > len(data) {
+			size = len(data) // a truncated file, take what's there
+		}
+		body := data[:size]
+		switch id {
+		case "fmt ":
+			if err := binary.Read(bytes.NewReader(body), binary.LittleEndian, &format); err != nil {
+				return nil, 0, fmt.Errorf("reading fmt chunk: %s", err.Error())
+			}
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return nil, 0, errors.New("data chunk before the fmt chunk")
+			}
+			samples, err := decodePCM(body, format.Format, int(format.BlockAlign), int(format.BitsPerSample))
+			return samples, int(format.SampleRate), err
+		}
+		data = data[size:]
+		if size%2 == 1 && len(data) > 0 { // chunks are padded to an even length
+			data = data[1:]
+		}
+	}
+	return nil, 0, errors.New("no data chunk")
+}
+
+// decodePCM reads the first sample of every frame into -1 to 1
+func decodePCM(body []byte, format uint16, blockAlign, bits int) ([]float64, error) {
+	const pcm, float = 1, 3
+	width := bits / 8
+	if blockAlign < width || width < 1 || (format == float && bits != 32) || (format != pcm && format != float) {
+		return nil, fmt.Errorf("unsupported WAV format %d at %d bits", format, bits)
+	}
+	samples := []float64{}
+	for i := 0; i+width <= len(body); i += blockAlign {
+		b := body[i : i+width]
+		switch {
+		case format == float:
+			samples = append(samples, float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		case bits == 8: // unsigned
+			samples = append(samples, (float64(b[0])-128)/128)
+		case bits == 16:
+			samples = append(samples, float64(int16(binary.LittleEndian.Uint16(b)))/(1<<15))
+		case bits == 24:
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			samples = append(samples, float64(v)/(1<<23))
+		case bits == 32:
+			samples = append(samples, float64(int32(binary.LittleEndian.Uint32(b)))/(1<<31))
+		default:
+			return nil, fmt.Errorf("unsupported WAV format %d at %d bits", format, bits)
+		}
+	}
+	return samples, nil
+}
+
+// newWavetable resamples one cycle to wavetableSize and scales it to peak at 1
+func newWavetable(cycle []float64) Wavetable {
+	t := make(Wavetable, wavetableSize)
+	peak := 0.0
+	for i := range t {
+		t[i] = lerpCycle(cycle, float64(i)/wavetableSize)
+		peak = math.Max(peak, math.Abs(t[i]))
+	}
+	if peak > 0 {
+		for i := range t {
+			t[i] /= peak
+		}
+	}
+	return t
+}
+
+// oscillate reads the table at a phase in cycles
+func (t Wavetable) oscillate(phase float64) float64 {
+	return lerpCycle(t, phase-math.Floor(phase))
+}
+
+// lerpCycle reads a cycle frac of the way through, interpolating and wrapping
+func lerpCycle(cycle []float64, frac float64) float64 {
+	pos := frac * float64(len(cycle))
+	i := int(pos)
+	a := cycle[i%len(cycle)]
+	b := cycle[(i+1)%len(cycle)]
+	return a + (b-a)*(pos-float64(i))
+}
@@ -0,0 +1,38 @@
+package synth
+
+// maxHaasDelay is the longest delay in seconds before the ear hears an echo
+const maxHaasDelay = 0.03
+
+// Widener scales the side of the mix by Width and delays the right channel by Haas seconds
+type Widener struct {
+	Width float64
+	Haas  float64
+
+	sampleRate float64
+	delayed    []float64 // the right channel's recent samples
+	idx        int
+}
+
+// NewWidener makes a widener for the context's sample rate
+func NewWidener(ac *AudioContext, width, haas float64) *Widener {
+	return &Widener{
+		Width:      width,
+		Haas:       haas,
+		sampleRate: float64(ac.SampleRate),
+		delayed:    make([]float64, int(maxHaasDelay*float64(ac.SampleRate))+1),
+	}
+}
+
+// ProcessStereo widens a pair of samples
+func (w *Widener) ProcessStereo(left, right float64) (float64, float64) {
+	mid, side := (left+right)/2, (left-right)/2*w.Width
+	left, right = mid+side, mid-side
+	if w.Haas <= 0 {
+		return left, right
+	}
+	w.delayed[w.idx] = right
+	delay := int(clamp(w.Haas, 0, maxHaasDelay) * w.sampleRate)
+	right = w.delayed[(w.idx-delay+len(w.delayed))%len(w.delayed)]
+	w.idx = (w.idx + 1) % len(w.delayed)
+	return left, right
+}
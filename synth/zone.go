@@ -0,0 +1,56 @@
+package synth
+
+// Zone is a range of keys, Low to High inclusive, played by one of several sounds
+type Zone struct {
+	Low, High int64
+}
+
+func (z Zone) contains(note int64) bool {
+	return z.Low <= note && note <= z.High
+}
+
+// MakeZoneHandlers shares a handler between zones, notes only going to the zones they fall in
+func MakeZoneHandlers(handler MidiHandler, zones ...Zone) []MidiHandler {
+	return shareHandler(handler, len(zones), func(e Event, to []bool) {
+		kind := e.Status & 0xF0
+		isNote := e.Status < 0xF0 && (kind == 0x80 || kind == 0x90 || kind == 0xA0)
+		for z := range zones {
+			to[z] = !isNote || zones[z].contains(e.Data1)
+		}
+	})
+}
+
+// shareHandler makes count handlers out of one, polled once a round, route marking where events go
+func shareHandler(handler MidiHandler, count int, route func(e Event, to []bool)) []MidiHandler {
+	queued := make([][]Event, count) // events waiting for each handler
+	spare := make([][]Event, count)  // the last events each was handed, reused once it's polled again
+	handed := make([]bool, count)    // whether each has had this round's events yet
+	to := make([]bool, count)
+	handlers := make([]MidiHandler, count)
+	for i := range handlers {
+		i := i
+		handlers[i] = func() []Event {
+			if handed[i] { // a new round
+				for _, e := range handler() {
+					route(e, to)
+					for h := range queued {
+						if to[h] {
+							queued[h] = append(queued[h], e)
+						}
+					}
+				}
+				for h := range handed {
+					handed[h] = false
+				}
+			}
+			handed[i] = true
+			events := queued[i]
+			queued[i], spare[i] = spare[i][:0], events
+			return events
+		}
+	}
+	for i := range handed {
+		handed[i] = true // so the first poll starts a round
+	}
+	return handlers
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucianthorr/simplesynth/synth"
+)
+
+// zone is one of the sounds a split or layered keyboard plays, from its own preset
+type zone struct {
+	preset string
+	keys   synth.Zone
+	base   *Preset // the settings before any zone's preset, which it starts from
+}
+
+// parseZones reads -split and -layer into zones, checking their presets, or none
+func parseZones() ([]zone, error) {
+	if *splitFlag < 0 && !*layerFlag {
+		return nil, nil
+	}
+	if *lowerFlag == "" || *upperFlag == "" {
+		return nil, errors.New("Splitting or layering the keyboard needs a preset for each zone, -lower and -upper")
+	}
+	if *splitFlag > 127 {
+		return nil, fmt.Errorf("Split must be a midi note, 0 to 127, got %d", *splitFlag)
+	}
+	base := presetFromFlags()
+	lower, upper := synth.Zone{Low: 0, High: 127}, synth.Zone{Low: 0, High: 127}
+	if !*layerFlag {
+		lower.High, upper.Low = *splitFlag-1, *splitFlag
+	}
+	zones := []zone{
+		{preset: *lowerFlag, keys: lower, base: base},
+		{preset: *upperFlag, keys: upper, base: base},
+	}
+	for _, z := range zones {
+		z.load()
+	}
+	if err := applyPreset(base); err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// maxPrograms is as many presets as PROGRAM CHANGE can pick between
+const maxPrograms = 128
+
+// parsePresetBank reads the -presetbank directory's presets into programs, in file name order
+func parsePresetBank() ([]zone, error) {
+	if *bankFlag == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(*bankFlag)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading preset bank: %s", err.Error())
+	}
+	base := presetFromFlags()
+	programs := []zone{}
+	for _, entry := range entries { // sorted by name
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".json") {
+			continue
+		}
+		programs = append(programs, zone{
+			preset: filepath.Join(*bankFlag, entry.Name()),
+			keys:   synth.Zone{Low: 0, High: 127},
+			base:   base,
+		})
+	}
+	if len(programs) == 0 {
+		return nil, fmt.Errorf("No presets in the bank %s", *bankFlag)
+	}
+	if len(programs) > maxPrograms {
+		return nil, fmt.Errorf("The bank %s has %d presets, program change only reaches %d", *bankFlag, len(programs), maxPrograms)
+	}
+	for i, p := range programs {
+		p.load()
+		fmt.Printf("program %d: %s\n", i+1, filepath.Base(p.preset)) // counting from 1, as most controllers do
+	}
+	if err := applyPreset(base); err != nil {
+		return nil, err
+	}
+	return programs, nil
+}
+
+// load sets the flags to the zone's preset and reads its sound from them
+func (z zone) load() patch {
+	if err := loadPresetOver(z.base, z.preset); err != nil {
+		log.Fatal(fmt.Errorf("Error loading preset: %s", err.Error()))
+	}
+	return parsePatch()
+}